@@ -2,9 +2,18 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
 	"log"
+	"os"
+	"slices"
+	"time"
 
+	"github.com/amandeep2102/image-processor/backend/processor"
 	"github.com/amandeep2102/image-processor/backend/worker"
+	"github.com/amandeep2102/image-processor/shared/config"
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/storage"
+	"github.com/amandeep2102/image-processor/shared/transform"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
@@ -16,16 +25,28 @@ var (
 )
 
 func main() {
-	var err error
-	db, err = sql.Open("postgres",
-		"host=localhost port=5432 user=imageuser password=imagepass dbname=imagedb sslmode=disable")
+	cfg, _, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+
+	db, err = sql.Open("postgres", cfg.Database.DSN)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Close()
 
-	// Initialize worker pool with 10 workers
-	workerPool = worker.NewPool(10, db)
+	// Originals, processed images and LUTs live in shared storage; processors
+	// still write their output to local scratch space first
+	processor.Files, err = storage.Open(cfg.Storage.URL, cfg.Storage.AccessKey, cfg.Storage.SecretKey)
+	if err != nil {
+		log.Fatal("Failed to open storage:", err)
+	}
+	processor.StorageBasePath = cfg.Backend.ScratchDir
+	worker.URLSigningKey = []byte(cfg.Signing.Key)
+
+	// Initialize worker pool
+	workerPool = worker.NewPool(cfg.Backend.Workers, db, storage.NewStore(db, processor.Files))
 	workerPool.Start()
 	defer workerPool.Stop()
 
@@ -36,31 +57,84 @@ func main() {
 	r.POST("/process/thumbnail", handleThumbnail)
 	r.POST("/process/filter", handleFilter)
 	r.POST("/process/convert", handleConvert)
+	r.POST("/process/watermark", handleWatermark)
+	r.POST("/process/text", handleText)
+	r.POST("/process/adjust", handleAdjust)
+	r.POST("/process/lut", handleLUT)
+	r.POST("/process/auto_enhance", handleAutoEnhance)
+	r.POST("/process/redact", handleRedact)
+	r.POST("/process/crop", handleCrop)
+	r.POST("/process/responsive", handleResponsive)
+	r.POST("/process/preset/:name", handlePreset)
+
+	// Sync processing for URL transformations
+	r.POST("/transform", handleTransform)
 	r.GET("/job/:job_id", handleGetJobResult)
 	r.GET("/workers/stats", handleWorkerStats)
 
-	log.Println("Backend server starting on :8081")
-	r.Run(":8081")
+	log.Println("Backend server starting on", cfg.Backend.Addr)
+	r.Run(cfg.Backend.Addr)
 }
 
 func handleHealth(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"status":         "ok",
-		"queue_size":     workerPool.GetQueueSize(),
-		"queue_capacity": workerPool.GetQueueCapacity(),
+	c.JSON(200, models.BackendHealthResponse{
+		Status:        "ok",
+		QueueSize:     workerPool.GetQueueSize(),
+		QueueCapacity: workerPool.GetQueueCapacity(),
 	})
 }
 
+// addOutputOptions adds the options accepted by every operation that writes
+// a new image.
+func addOutputOptions(params map[string]interface{}, o models.OutputOptions) error {
+	if o.OutputFormat != "" {
+		format, err := processor.NormalizeFormat(o.OutputFormat)
+		if err != nil {
+			return err
+		}
+		params["output_format"] = format
+	}
+	if o.Quality != 0 {
+		params["quality"] = o.Quality
+	}
+	if o.StripMetadata != nil {
+		params["strip_metadata"] = *o.StripMetadata
+	}
+	return nil
+}
+
+// addFrameOptions adds what is written for animated GIFs by the operations
+// that process them frame by frame.
+func addFrameOptions(params map[string]interface{}, o models.FrameOptions) {
+	if o.Frame != nil {
+		params["frame"] = *o.Frame
+	}
+	if o.Sprite {
+		params["sprite"] = true
+	}
+	if o.SpriteColumns != 0 {
+		params["sprite_columns"] = o.SpriteColumns
+	}
+}
+
 // Async processing (returns immediately with job ID)
 func handleResize(c *gin.Context) {
-	var req struct {
-		ImageID string `json:"image_id"`
-		Width   int    `json:"width"`
-		Height  int    `json:"height"`
-	}
+	var req models.ResizeRequest
 
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.Width < 0 || req.Height < 0 || req.Width == 0 && req.Height == 0 {
+		c.JSON(400, models.ErrorResponse{Error: "width or height must be positive"})
+		return
+	}
+
+	// Record the filter actually used, not the preset name
+	resample, err := processor.ResolveResample(req.Resample)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -70,36 +144,93 @@ func handleResize(c *gin.Context) {
 		ImageID:   req.ImageID,
 		Operation: "resize",
 		Parameters: map[string]interface{}{
-			"width":  req.Width,
-			"height": req.Height,
+			"resample":   resample,
+			"width":      req.Width,
+			"height":     req.Height,
+			"mode":       req.Mode,
+			"background": req.Background,
+			"position":   req.Position,
 		},
 	}
+	if req.AllowUpscale != nil {
+		job.Parameters["allow_upscale"] = *req.AllowUpscale
+	}
+	addFrameOptions(job.Parameters, req.FrameOptions)
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	// Submit to worker pool (non-blocking)
 	if err := workerPool.Submit(job); err != nil {
-		c.JSON(503, gin.H{
-			"error":   "Worker pool is busy",
-			"message": err.Error(),
+		c.JSON(503, models.ErrorResponse{
+			Error:   "Worker pool is busy",
+			Message: err.Error(),
 		})
 		return
 	}
 
 	// Return immediately with job ID
-	c.JSON(202, gin.H{
-		"job_id":     job.JobID,
-		"message":    "Job submitted successfully",
-		"queue_size": workerPool.GetQueueSize(),
+	c.JSON(202, models.JobResponse{
+		JobID:     job.JobID,
+		Message:   "Job submitted successfully",
+		QueueSize: workerPool.GetQueueSize(),
 	})
 }
 
-func handleThumbnail(c *gin.Context) {
-	var req struct {
-		ImageID string `json:"image_id"`
-		Size    int    `json:"size"`
+func handleCrop(c *gin.Context) {
+	var req models.CropRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.Width <= 0 || req.Height <= 0 || req.X < 0 || req.Y < 0 {
+		c.JSON(400, models.ErrorResponse{Error: "width and height must be positive and x, y must not be negative"})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "crop",
+		Parameters: map[string]interface{}{
+			"x":      req.X,
+			"y":      req.Y,
+			"width":  req.Width,
+			"height": req.Height,
+		},
+	}
+	addFrameOptions(job.Parameters, req.FrameOptions)
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
 	}
 
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleThumbnail(c *gin.Context) {
+	var req models.ThumbnailRequest
+
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	// Record the filter actually used, not the preset name
+	resample, err := processor.ResolveResample(req.Resample)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -108,63 +239,90 @@ func handleThumbnail(c *gin.Context) {
 		ImageID:   req.ImageID,
 		Operation: "thumbnail",
 		Parameters: map[string]interface{}{
-			"size": req.Size,
+			"resample": resample,
+			"size":     req.Size,
 		},
 	}
+	addFrameOptions(job.Parameters, req.FrameOptions)
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	if err := workerPool.Submit(job); err != nil {
-		c.JSON(503, gin.H{"error": err.Error()})
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(202, gin.H{
-		"job_id":  job.JobID,
-		"message": "Job submitted successfully",
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
 	})
 }
 
 func handleFilter(c *gin.Context) {
-	var req struct {
-		ImageID    string  `json:"image_id"`
-		FilterType string  `json:"filter_type"`
-		Intensity  float64 `json:"intensity"`
-	}
+	var req models.FilterRequest
 
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	if req.FilterType == "convolve" {
+		if err := processor.ValidateKernel(req.Kernel); err != nil {
+			c.JSON(400, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
 	job := worker.Job{
 		JobID:     uuid.New().String(),
 		ImageID:   req.ImageID,
 		Operation: "filter",
 		Parameters: map[string]interface{}{
 			"filter_type": req.FilterType,
-			"intensity":   req.Intensity,
 		},
 	}
+	if req.Intensity != nil {
+		job.Parameters["intensity"] = *req.Intensity
+	}
+	if req.Levels != nil {
+		job.Parameters["levels"] = *req.Levels
+	}
+	if req.FilterType == "convolve" {
+		job.Parameters["kernel"] = req.Kernel
+		job.Parameters["normalize"] = req.Normalize
+		job.Parameters["abs"] = req.Abs
+		job.Parameters["bias"] = req.Bias
+	}
+	addFrameOptions(job.Parameters, req.FrameOptions)
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	if err := workerPool.Submit(job); err != nil {
-		c.JSON(503, gin.H{"error": err.Error()})
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
-	c.JSON(202, gin.H{
-		"job_id":  job.JobID,
-		"message": "Job submitted successfully",
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
 	})
 }
 
 func handleConvert(c *gin.Context) {
-	var req struct {
-		ImageID string `json:"image_id"`
-		Format  string `json:"format"`
-		Quality int    `json:"quality"`
-	}
+	var req models.ConvertRequest
 
 	if err := c.BindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": err.Error()})
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	format, err := processor.NormalizeFormat(req.Format)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
 		return
 	}
 
@@ -173,19 +331,501 @@ func handleConvert(c *gin.Context) {
 		ImageID:   req.ImageID,
 		Operation: "convert",
 		Parameters: map[string]interface{}{
-			"format":  req.Format,
-			"quality": req.Quality,
+			"format":      format,
+			"compression": req.Compression,
+		},
+	}
+	if req.Quality != 0 {
+		job.Parameters["quality"] = req.Quality
+	}
+	if req.Predictor != nil {
+		job.Parameters["predictor"] = *req.Predictor
+	}
+	if req.Colors != nil {
+		job.Parameters["colors"] = *req.Colors
+	}
+	if req.Dither != nil {
+		job.Parameters["dither"] = *req.Dither
+	}
+	if req.StripMetadata != nil {
+		job.Parameters["strip_metadata"] = *req.StripMetadata
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleWatermark(c *gin.Context) {
+	var req models.WatermarkRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.LogoID == "" {
+		c.JSON(400, models.ErrorResponse{Error: "logo_id is required"})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "watermark",
+		Parameters: map[string]interface{}{
+			"logo_id":  req.LogoID,
+			"position": req.Position,
+			"tiled":    req.Tiled,
+		},
+	}
+	if req.Margin != nil {
+		job.Parameters["margin"] = *req.Margin
+	}
+	if req.Scale != nil {
+		job.Parameters["scale"] = *req.Scale
+	}
+	if req.Opacity != nil {
+		job.Parameters["opacity"] = *req.Opacity
+	}
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleText(c *gin.Context) {
+	var req models.TextRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.Text == "" {
+		c.JSON(400, models.ErrorResponse{Error: "text is required"})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "text",
+		Parameters: map[string]interface{}{
+			"text":          req.Text,
+			"font":          req.Font,
+			"color":         req.Color,
+			"stroke_color":  req.StrokeColor,
+			"stroke_width":  req.StrokeWidth,
+			"shadow_color":  req.ShadowColor,
+			"shadow_offset": req.ShadowOffset,
+			"align":         req.Align,
+			"position":      req.Position,
+		},
+	}
+	if req.FontSize != nil {
+		job.Parameters["font_size"] = *req.FontSize
+	}
+	if req.Margin != nil {
+		job.Parameters["margin"] = *req.Margin
+	}
+	if req.BoxWidth != nil {
+		job.Parameters["box_width"] = *req.BoxWidth
+	}
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleAdjust(c *gin.Context) {
+	var req models.AdjustRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.Brightness == 0 && req.Contrast == 0 && req.Gamma == nil && req.Saturation == 0 && req.Hue == 0 {
+		c.JSON(400, models.ErrorResponse{Error: "at least one adjustment is required"})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "adjust",
+		Parameters: map[string]interface{}{
+			"brightness": req.Brightness,
+			"contrast":   req.Contrast,
+			"saturation": req.Saturation,
+			"hue":        req.Hue,
+		},
+	}
+	if req.Gamma != nil {
+		job.Parameters["gamma"] = *req.Gamma
+	}
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleLUT(c *gin.Context) {
+	var req models.LUTRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if req.LUT == "" {
+		c.JSON(400, models.ErrorResponse{Error: "lut is required"})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "lut",
+		Parameters: map[string]interface{}{
+			"lut": req.LUT,
+		},
+	}
+	if req.Strength != nil {
+		job.Parameters["strength"] = *req.Strength
+	}
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleAutoEnhance(c *gin.Context) {
+	var req models.AutoEnhanceRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	job := worker.Job{
+		JobID:      uuid.New().String(),
+		ImageID:    req.ImageID,
+		Operation:  "auto_enhance",
+		Parameters: map[string]interface{}{},
+	}
+	if req.WhiteBalance != nil {
+		job.Parameters["white_balance"] = *req.WhiteBalance
+	}
+	if req.Levels != nil {
+		job.Parameters["levels"] = *req.Levels
+	}
+	if req.Contrast != nil {
+		job.Parameters["contrast"] = *req.Contrast
+	}
+	if req.Clip != nil {
+		job.Parameters["clip"] = *req.Clip
+	}
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleRedact(c *gin.Context) {
+	var req models.RedactRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	regions := make([]processor.Region, len(req.Regions))
+	for i, r := range req.Regions {
+		regions[i] = processor.Region(r)
+	}
+	if err := processor.ValidateRegions(regions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "redact",
+		Parameters: map[string]interface{}{
+			"regions": req.Regions,
+			"mode":    req.Mode,
+			"color":   req.Color,
+		},
+	}
+	if req.BlockSize != nil {
+		job.Parameters["block_size"] = *req.BlockSize
+	}
+	if req.Sigma != nil {
+		job.Parameters["sigma"] = *req.Sigma
+	}
+	if err := addOutputOptions(job.Parameters, models.OutputOptions{OutputFormat: req.OutputFormat, Quality: req.Quality}); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+func handleResponsive(c *gin.Context) {
+	var req models.ResponsiveRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if len(req.Widths) > 20 {
+		c.JSON(400, models.ErrorResponse{Error: "at most 20 widths are allowed"})
+		return
+	}
+	for _, width := range req.Widths {
+		if width <= 0 {
+			c.JSON(400, models.ErrorResponse{Error: "widths must be positive"})
+			return
+		}
+	}
+	// "jpg" and "jpeg" name the same variants
+	var formats []string
+	for _, format := range req.Formats {
+		normalized, err := processor.NormalizeFormat(format)
+		if err != nil {
+			c.JSON(400, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if !slices.Contains(formats, normalized) {
+			formats = append(formats, normalized)
+		}
+	}
+	resample, err := processor.ResolveResample(req.Resample)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "responsive",
+		Parameters: map[string]interface{}{
+			"resample": resample,
+		},
+	}
+	if len(req.Widths) > 0 {
+		job.Parameters["widths"] = req.Widths
+	}
+	if len(formats) > 0 {
+		job.Parameters["formats"] = formats
+	}
+	if req.AllowUpscale != nil {
+		job.Parameters["allow_upscale"] = *req.AllowUpscale
+	}
+	if err := addOutputOptions(job.Parameters, req.OutputOptions); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := workerPool.Submit(job); err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		GroupID: job.JobID,
+		Message: "Job submitted successfully",
+	})
+}
+
+// handlePreset runs a stored preset, by default its latest version. The
+// preset name, version and steps are recorded with the processed image.
+func handlePreset(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.RunPresetRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var version int
+	var encoded []byte
+	err := db.QueryRow(`
+        SELECT version, steps FROM presets
+        WHERE name = $1 AND ($2 = 0 OR version = $2)
+        ORDER BY version DESC
+        LIMIT 1
+    `, name, req.Version).Scan(&version, &encoded)
+	if err == sql.ErrNoRows {
+		c.JSON(404, models.ErrorResponse{Error: "Preset not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var steps []models.PresetStep
+	if err := json.Unmarshal(encoded, &steps); err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Invalid preset steps"})
+		return
+	}
+
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "preset",
+		Parameters: map[string]interface{}{
+			"preset":  name,
+			"version": version,
+			"steps":   steps,
 		},
 	}
 
 	if err := workerPool.Submit(job); err != nil {
-		c.JSON(503, gin.H{"error": err.Error()})
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(202, models.JobResponse{
+		JobID:   job.JobID,
+		Preset:  name,
+		Version: version,
+		Message: "Job submitted successfully",
+	})
+}
+
+// handleTransform runs a URL transformation and waits for it, so the
+// frontend can serve the result in the same request.
+func handleTransform(c *gin.Context) {
+	var req models.TransformRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	options, err := transform.Parse(req.Transform)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	step := options.Step()
+	job := worker.Job{
+		JobID:     uuid.New().String(),
+		ImageID:   req.ImageID,
+		Operation: "transform",
+		Parameters: map[string]interface{}{
+			"transform": options.String(),
+			"steps":     []models.PresetStep{step},
+		},
+	}
+
+	if req.SourceProcessedID != "" {
+		var sourceKey string
+		err := db.QueryRow(`
+            SELECT processed_path FROM processed_images WHERE id = $1 AND original_image_id = $2
+        `, req.SourceProcessedID, req.ImageID).Scan(&sourceKey)
+		if err != nil {
+			c.JSON(404, models.ErrorResponse{Error: "Processed image not found"})
+			return
+		}
+		sourcePath, err := processor.LocalFile(sourceKey)
+		if err != nil {
+			c.JSON(404, models.ErrorResponse{Error: "Processed image file not found"})
+			return
+		}
+		step.Parameters["source_path"] = sourcePath
+		job.Parameters["source_processed_id"] = req.SourceProcessedID
+	}
+
+	result, err := workerPool.SubmitAndWait(job, 30*time.Second)
+	if err != nil {
+		c.JSON(503, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !result.Success {
+		c.JSON(422, models.ErrorResponse{Error: result.Message})
 		return
 	}
 
-	c.JSON(202, gin.H{
-		"job_id":  job.JobID,
-		"message": "Job submitted successfully",
+	c.JSON(200, models.TransformResponse{
+		ProcessedID: result.ProcessedID,
+		ContentHash: result.ContentHash,
+		Transform:   options.String(),
 	})
 }
 
@@ -195,10 +835,10 @@ func handleGetJobResult(c *gin.Context) {
 
 	result, found := workerPool.GetResult(jobID)
 	if !found {
-		c.JSON(404, gin.H{
-			"job_id":  jobID,
-			"status":  "pending",
-			"message": "Job is still processing or not found",
+		c.JSON(404, models.JobStatusResponse{
+			JobID:   jobID,
+			Status:  "pending",
+			Message: "Job is still processing or not found",
 		})
 		return
 	}
@@ -208,9 +848,9 @@ func handleGetJobResult(c *gin.Context) {
 
 // NEW: Worker statistics
 func handleWorkerStats(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"queue_size":     workerPool.GetQueueSize(),
-		"queue_capacity": workerPool.GetQueueCapacity(),
-		"queue_usage":    float64(workerPool.GetQueueSize()) / float64(workerPool.GetQueueCapacity()) * 100,
+	c.JSON(200, models.WorkerStatsResponse{
+		QueueSize:     workerPool.GetQueueSize(),
+		QueueCapacity: workerPool.GetQueueCapacity(),
+		QueueUsage:    float64(workerPool.GetQueueSize()) / float64(workerPool.GetQueueCapacity()) * 100,
 	})
 }
@@ -0,0 +1,173 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+// Adjust applies any combination of brightness, contrast, gamma, saturation
+// and hue rotation. Adjustments are applied in that order.
+func Adjust(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+
+	brightness, err := floatParam(params, "brightness", 0)
+	if err != nil {
+		return "", err
+	}
+	contrast, err := floatParam(params, "contrast", 0)
+	if err != nil {
+		return "", err
+	}
+	gamma, err := floatParam(params, "gamma", 1)
+	if err != nil {
+		return "", err
+	}
+	saturation, err := floatParam(params, "saturation", 0)
+	if err != nil {
+		return "", err
+	}
+	hue, err := floatParam(params, "hue", 0)
+	if err != nil {
+		return "", err
+	}
+
+	if brightness < -100 || brightness > 100 || contrast < -100 || contrast > 100 {
+		return "", fmt.Errorf("brightness and contrast must be between -100 and 100")
+	}
+	if saturation < -100 || saturation > 500 {
+		return "", fmt.Errorf("saturation must be between -100 and 500")
+	}
+	if gamma <= 0 {
+		return "", fmt.Errorf("gamma must be positive")
+	}
+	if hue < -180 || hue > 180 {
+		return "", fmt.Errorf("hue must be between -180 and 180")
+	}
+
+	adjusted := img
+	if brightness != 0 {
+		adjusted = imaging.AdjustBrightness(adjusted, brightness)
+	}
+	if contrast != 0 {
+		adjusted = imaging.AdjustContrast(adjusted, contrast)
+	}
+	if gamma != 1 {
+		adjusted = imaging.AdjustGamma(adjusted, gamma)
+	}
+	if saturation != 0 {
+		adjusted = imaging.AdjustSaturation(adjusted, saturation)
+	}
+	if hue != 0 {
+		adjusted = adjustHue(adjusted, hue)
+	}
+
+	outputPath := scratchPath(params, fmt.Sprintf("%s_adjust_b%g_c%g_g%g_s%g_h%g",
+		imageID, brightness, contrast, gamma, saturation, hue))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	outputPath, err = saveOutput(adjusted, originalPath, outputPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to save adjusted image: %v", err)
+	}
+
+	return outputPath, nil
+}
+
+// adjustHue rotates the hue of every pixel by the given number of degrees.
+func adjustHue(img image.Image, degrees float64) *image.NRGBA {
+	shift := degrees / 360
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		h, s, l := rgbToHSL(c.R, c.G, c.B)
+		h = math.Mod(h+shift+1, 1)
+		r, g, b := hslToRGB(h, s, l)
+		return color.NRGBA{R: r, G: g, B: b, A: c.A}
+	})
+}
+
+func rgbToHSL(r8, g8, b8 uint8) (h, s, l float64) {
+	r, g, b := float64(r8)/255, float64(g8)/255, float64(b8)/255
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h / 6, s, l
+}
+
+func hslToRGB(h, s, l float64) (uint8, uint8, uint8) {
+	if s == 0 {
+		v := clampUint8(l * 255)
+		return v, v, v
+	}
+
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+
+	r := hueToRGB(p, q, h+1.0/3)
+	g := hueToRGB(p, q, h)
+	b := hueToRGB(p, q, h-1.0/3)
+	return clampUint8(r * 255), clampUint8(g * 255), clampUint8(b * 255)
+}
+
+func hueToRGB(p, q, t float64) float64 {
+	if t < 0 {
+		t++
+	}
+	if t > 1 {
+		t--
+	}
+	switch {
+	case t < 1.0/6:
+		return p + (q-p)*6*t
+	case t < 0.5:
+		return q
+	case t < 2.0/3:
+		return p + (q-p)*(2.0/3-t)*6
+	default:
+		return p
+	}
+}
+
+func clampUint8(v float64) uint8 {
+	return uint8(math.Min(math.Max(math.Round(v), 0), 255))
+}
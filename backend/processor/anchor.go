@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+var anchors = map[string]imaging.Anchor{
+	"center":       imaging.Center,
+	"top_left":     imaging.TopLeft,
+	"top":          imaging.Top,
+	"top_right":    imaging.TopRight,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"bottom_left":  imaging.BottomLeft,
+	"bottom":       imaging.Bottom,
+	"bottom_right": imaging.BottomRight,
+}
+
+func parseAnchor(name string) (imaging.Anchor, error) {
+	anchor, ok := anchors[name]
+	if !ok {
+		return imaging.Center, fmt.Errorf("unknown position: %s", name)
+	}
+	return anchor, nil
+}
+
+// anchorPoint returns the top-left corner at which an item of the given size
+// is placed inside bounds, keeping margin pixels away from the anchored edges.
+func anchorPoint(bounds image.Rectangle, size image.Point, anchor imaging.Anchor, margin int) image.Point {
+	var x, y int
+
+	switch anchor {
+	case imaging.TopLeft, imaging.Left, imaging.BottomLeft:
+		x = bounds.Min.X + margin
+	case imaging.TopRight, imaging.Right, imaging.BottomRight:
+		x = bounds.Max.X - size.X - margin
+	default:
+		x = bounds.Min.X + (bounds.Dx()-size.X)/2
+	}
+
+	switch anchor {
+	case imaging.TopLeft, imaging.Top, imaging.TopRight:
+		y = bounds.Min.Y + margin
+	case imaging.BottomLeft, imaging.Bottom, imaging.BottomRight:
+		y = bounds.Max.Y - size.Y - margin
+	default:
+		y = bounds.Min.Y + (bounds.Dy()-size.Y)/2
+	}
+
+	return image.Pt(x, y)
+}
@@ -0,0 +1,219 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+
+	"github.com/disintegration/imaging"
+)
+
+// imaging.Open only decodes the first frame of an animated GIF. Operations
+// that support animation hand their per-frame work to processImage, which
+// applies it to every frame and re-encodes with the original delays and loop
+// count. Two parameters change what is written:
+//   - frame:  index of a single frame to extract as a still image
+//   - sprite: lay all frames out in a grid (sprite_columns, default square)
+
+type transform func(image.Image) (image.Image, error)
+
+// Animations are decoded and composited in full, so their size is limited.
+// The pixel limit counts the canvas once per frame.
+const (
+	maxAnimationFrames = 500
+	maxAnimationPixels = 50_000_000
+)
+
+func processImage(sourcePath, basePath string, params map[string]interface{}, fn transform) (string, error) {
+	frame, err := intParam(params, "frame", -1)
+	if err != nil {
+		return "", err
+	}
+	sprite, err := boolParam(params, "sprite", false)
+	if err != nil {
+		return "", err
+	}
+	if frame >= 0 && sprite {
+		return "", fmt.Errorf("frame and sprite cannot be combined")
+	}
+
+	frames, anim, err := loadFrames(sourcePath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+
+	switch {
+	case frame >= 0:
+		if frame >= len(frames) {
+			return "", fmt.Errorf("frame %d out of range (image has %d frames)", frame, len(frames))
+		}
+		frames = frames[frame : frame+1]
+		basePath = fmt.Sprintf("%s_frame%d", basePath, frame)
+	case sprite:
+		basePath += "_sprite"
+	}
+
+	for i, f := range frames {
+		if frames[i], err = fn(f); err != nil {
+			return "", err
+		}
+	}
+
+	var outputPath string
+	switch {
+	case sprite:
+		columns, err := intParam(params, "sprite_columns", int(math.Ceil(math.Sqrt(float64(len(frames))))))
+		if err != nil {
+			return "", err
+		}
+		if columns < 1 {
+			return "", fmt.Errorf("sprite_columns must be positive")
+		}
+		outputPath, err = saveOutput(spriteSheet(frames, columns), sourcePath, basePath, params)
+	case anim != nil && frame < 0:
+		outputPath, err = saveAnimation(frames, anim, sourcePath, basePath, params)
+	default:
+		outputPath, err = saveOutput(frames[0], sourcePath, basePath, params)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save image: %v", err)
+	}
+	return outputPath, nil
+}
+
+// loadFrames returns every frame of an animated GIF, fully composited, along
+// with the decoded animation. Any other image is returned as a single frame
+// and a nil animation.
+func loadFrames(path string, params map[string]interface{}) ([]image.Image, *gif.GIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	if config, format, err := image.DecodeConfig(f); err == nil && format == "gif" {
+		canvas := config.Width * config.Height
+		if canvas > maxAnimationPixels {
+			return nil, nil, fmt.Errorf("GIF canvas of %dx%d exceeds the limit of %d pixels", config.Width, config.Height, maxAnimationPixels)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		anim, err := gif.DecodeAll(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(anim.Image) > maxAnimationFrames {
+			return nil, nil, fmt.Errorf("animation has %d frames, the limit is %d", len(anim.Image), maxAnimationFrames)
+		}
+		if len(anim.Image) > 1 && len(anim.Image)*canvas > maxAnimationPixels {
+			return nil, nil, fmt.Errorf("animation of %d frames at %dx%d exceeds the limit of %d pixels", len(anim.Image), config.Width, config.Height, maxAnimationPixels)
+		}
+		if len(anim.Image) > 1 {
+			return compositeFrames(anim), anim, nil
+		}
+	}
+
+	img, err := openImage(path, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []image.Image{img}, nil, nil
+}
+
+// compositeFrames renders each GIF frame onto the full canvas, honoring the
+// disposal method of the frame before it.
+func compositeFrames(anim *gif.GIF) []image.Image {
+	canvas := image.NewNRGBA(image.Rect(0, 0, anim.Config.Width, anim.Config.Height))
+	frames := make([]image.Image, len(anim.Image))
+
+	for i, frame := range anim.Image {
+		var disposal byte
+		if i < len(anim.Disposal) {
+			disposal = anim.Disposal[i]
+		}
+
+		var previous *image.NRGBA
+		if disposal == gif.DisposalPrevious {
+			previous = imaging.Clone(canvas)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		frames[i] = imaging.Clone(canvas)
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return frames
+}
+
+// saveAnimation quantizes the processed frames and writes them as an
+// animated GIF. Other output formats cannot hold animation, so they require
+// picking a frame or a sprite sheet instead of silently dropping frames.
+func saveAnimation(frames []image.Image, anim *gif.GIF, sourcePath, basePath string, params map[string]interface{}) (string, error) {
+	format, err := outputFormat(sourcePath, params)
+	if err != nil {
+		return "", err
+	}
+	if format != "gif" {
+		return "", fmt.Errorf("animated images can only be written as gif; set frame or sprite for %s output", format)
+	}
+
+	colors, drawer, err := gifOptions(params)
+	if err != nil {
+		return "", err
+	}
+
+	// Frames are full canvases, so each one replaces the previous entirely
+	out := &gif.GIF{
+		Image:     make([]*image.Paletted, len(frames)),
+		Delay:     anim.Delay,
+		Disposal:  make([]byte, len(frames)),
+		LoopCount: anim.LoopCount,
+	}
+	for i, frame := range frames {
+		bounds := frame.Bounds()
+		palette := medianCut{}.Quantize(make(color.Palette, 0, colors), frame)
+		paletted := image.NewPaletted(bounds, palette)
+		drawer.Draw(paletted, bounds, frame, bounds.Min)
+
+		out.Image[i] = paletted
+		out.Disposal[i] = gif.DisposalBackground
+	}
+
+	outputPath := basePath + "." + outputFormats["gif"].Extension
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return "", err
+	}
+	if err := gif.EncodeAll(f, out); err != nil {
+		f.Close()
+		os.Remove(outputPath)
+		return "", err
+	}
+	return outputPath, f.Close()
+}
+
+// spriteSheet lays the frames out left to right, top to bottom, in cells the
+// size of the first frame.
+func spriteSheet(frames []image.Image, columns int) image.Image {
+	columns = min(columns, len(frames))
+	rows := (len(frames) + columns - 1) / columns
+	cell := frames[0].Bounds().Size()
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, cell.X*columns, cell.Y*rows))
+	for i, frame := range frames {
+		pos := image.Pt(i%columns*cell.X, i/columns*cell.Y)
+		draw.Draw(sheet, image.Rectangle{pos, pos.Add(cell)}, frame, frame.Bounds().Min, draw.Src)
+	}
+	return sheet
+}
@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// maxKernelValue bounds kernel weights to keep results meaningful.
+const maxKernelValue = 1000
+
+// ValidateKernel checks that kernel is a 3x3 or 5x5 matrix of finite,
+// reasonably sized weights.
+func ValidateKernel(kernel [][]float64) error {
+	size := len(kernel)
+	if size != 3 && size != 5 {
+		return fmt.Errorf("kernel must be 3x3 or 5x5, got %d rows", size)
+	}
+	for i, row := range kernel {
+		if len(row) != size {
+			return fmt.Errorf("kernel row %d has %d values, expected %d", i, len(row), size)
+		}
+		for _, v := range row {
+			if math.IsNaN(v) || math.IsInf(v, 0) || math.Abs(v) > maxKernelValue {
+				return fmt.Errorf("kernel values must be finite and within ±%d", maxKernelValue)
+			}
+		}
+	}
+	return nil
+}
+
+// kernelParam reads the kernel either as [][]float64 (set by handlers) or as
+// nested JSON arrays.
+func kernelParam(params map[string]interface{}) ([][]float64, error) {
+	switch v := params["kernel"].(type) {
+	case [][]float64:
+		return v, nil
+	case []interface{}:
+		kernel := make([][]float64, len(v))
+		for i, row := range v {
+			values, ok := row.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("kernel row %d is not an array", i)
+			}
+			for _, value := range values {
+				f, ok := value.(float64)
+				if !ok {
+					return nil, fmt.Errorf("kernel row %d contains a non-numeric value", i)
+				}
+				kernel[i] = append(kernel[i], f)
+			}
+		}
+		return kernel, nil
+	case nil:
+		return nil, fmt.Errorf("kernel is required for convolve")
+	default:
+		return nil, fmt.Errorf("invalid kernel type: %T", v)
+	}
+}
+
+func convolveEffect(img image.Image, params map[string]interface{}) (image.Image, error) {
+	kernel, err := kernelParam(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateKernel(kernel); err != nil {
+		return nil, err
+	}
+
+	normalize, err := boolParam(params, "normalize", false)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := boolParam(params, "abs", false)
+	if err != nil {
+		return nil, err
+	}
+	bias, err := intParam(params, "bias", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &imaging.ConvolveOptions{Normalize: normalize, Abs: abs, Bias: bias}
+
+	if len(kernel) == 3 {
+		var k [9]float64
+		for i, row := range kernel {
+			copy(k[i*3:], row)
+		}
+		return imaging.Convolve3x3(img, k, options), nil
+	}
+
+	var k [25]float64
+	for i, row := range kernel {
+		copy(k[i*5:], row)
+	}
+	return imaging.Convolve5x5(img, k, options), nil
+}
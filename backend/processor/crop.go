@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+// Crop cuts the rectangle given by x, y, width and height out of the image.
+func Crop(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", err
+	}
+
+	var rect [4]int
+	for i, key := range []string{"x", "y", "width", "height"} {
+		if rect[i], err = intParam(params, key, 0); err != nil {
+			return "", err
+		}
+	}
+	x, y, width, height := rect[0], rect[1], rect[2], rect[3]
+	if width <= 0 || height <= 0 {
+		return "", fmt.Errorf("width and height must be positive")
+	}
+	area := image.Rect(x, y, x+width, y+height)
+
+	crop := func(img image.Image) (image.Image, error) {
+		bounds := img.Bounds()
+		if !area.Add(bounds.Min).In(bounds) {
+			return nil, fmt.Errorf("crop area %v is outside the %dx%d image", area, bounds.Dx(), bounds.Dy())
+		}
+		return imaging.Crop(img, area.Add(bounds.Min)), nil
+	}
+
+	outputPath := scratchPath(params, fmt.Sprintf("%s_crop_%d_%d_%dx%d", imageID, x, y, width, height))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	return processImage(originalPath, outputPath, params, crop)
+}
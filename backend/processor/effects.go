@@ -0,0 +1,160 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+type effect func(img image.Image, params map[string]interface{}) (image.Image, error)
+
+// effects are the filters that accept a 0-1 intensity; the result is blended
+// with the original image by that amount.
+var effects = map[string]effect{
+	"sepia":       func(img image.Image, _ map[string]interface{}) (image.Image, error) { return sepia(img), nil },
+	"invert":      func(img image.Image, _ map[string]interface{}) (image.Image, error) { return imaging.Invert(img), nil },
+	"posterize":   posterizeEffect,
+	"vignette":    func(img image.Image, _ map[string]interface{}) (image.Image, error) { return vignette(img, 1), nil },
+	"emboss":      func(img image.Image, _ map[string]interface{}) (image.Image, error) { return emboss(img), nil },
+	"edge_detect": func(img image.Image, _ map[string]interface{}) (image.Image, error) { return sobel(img), nil },
+	"convolve":    convolveEffect,
+
+	// Presets composed from the primitives above
+	"vintage": func(img image.Image, _ map[string]interface{}) (image.Image, error) {
+		toned := blend(img, sepia(img), 0.6)
+		return vignette(imaging.AdjustContrast(toned, -10), 0.4), nil
+	},
+	"noir": func(img image.Image, _ map[string]interface{}) (image.Image, error) {
+		return vignette(imaging.AdjustContrast(imaging.Grayscale(img), 30), 0.3), nil
+	},
+	"warm": func(img image.Image, _ map[string]interface{}) (image.Image, error) {
+		return tint(imaging.AdjustSaturation(img, 10), 1.1, 1.0, 0.9), nil
+	},
+	"cool": func(img image.Image, _ map[string]interface{}) (image.Image, error) {
+		return tint(img, 0.9, 1.0, 1.1), nil
+	},
+	"dramatic": func(img image.Image, _ map[string]interface{}) (image.Image, error) {
+		punchy := imaging.Sharpen(imaging.AdjustSaturation(imaging.AdjustContrast(img, 40), -20), 1)
+		return vignette(punchy, 0.5), nil
+	},
+}
+
+func filterNames() []string {
+	names := []string{"blur", "sharpen", "grayscale"}
+	for name := range effects {
+		names = append(names, name)
+	}
+	sort.Strings(names[3:])
+	return names
+}
+
+// blend mixes filtered over original with the given strength (0-1).
+func blend(original, filtered image.Image, strength float64) image.Image {
+	if strength >= 1 {
+		return filtered
+	}
+	return imaging.Overlay(original, filtered, original.Bounds().Min, strength)
+}
+
+func sepia(img image.Image) *image.NRGBA {
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return color.NRGBA{
+			R: clampUint8(0.393*r + 0.769*g + 0.189*b),
+			G: clampUint8(0.349*r + 0.686*g + 0.168*b),
+			B: clampUint8(0.272*r + 0.534*g + 0.131*b),
+			A: c.A,
+		}
+	})
+}
+
+func tint(img image.Image, r, g, b float64) *image.NRGBA {
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{
+			R: clampUint8(float64(c.R) * r),
+			G: clampUint8(float64(c.G) * g),
+			B: clampUint8(float64(c.B) * b),
+			A: c.A,
+		}
+	})
+}
+
+func posterizeEffect(img image.Image, params map[string]interface{}) (image.Image, error) {
+	levels, err := intParam(params, "levels", 4)
+	if err != nil {
+		return nil, err
+	}
+	if levels < 2 || levels > 255 {
+		return nil, fmt.Errorf("levels must be between 2 and 255")
+	}
+
+	step := 255 / float64(levels-1)
+	quantize := func(v uint8) uint8 {
+		return clampUint8(math.Round(float64(v)/step) * step)
+	}
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: quantize(c.R), G: quantize(c.G), B: quantize(c.B), A: c.A}
+	}), nil
+}
+
+// vignette darkens the image towards the corners; strength 1 turns the
+// corners black.
+func vignette(img image.Image, strength float64) *image.NRGBA {
+	dst := imaging.Clone(img)
+	w, h := dst.Bounds().Dx(), dst.Bounds().Dy()
+	cx, cy := float64(w)/2, float64(h)/2
+	maxDist := math.Hypot(cx, cy)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / maxDist
+			factor := 1 - strength*d*d
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = clampUint8(float64(dst.Pix[i]) * factor)
+			dst.Pix[i+1] = clampUint8(float64(dst.Pix[i+1]) * factor)
+			dst.Pix[i+2] = clampUint8(float64(dst.Pix[i+2]) * factor)
+		}
+	}
+	return dst
+}
+
+func emboss(img image.Image) *image.NRGBA {
+	kernel := [9]float64{
+		-2, -1, 0,
+		-1, 1, 1,
+		0, 1, 2,
+	}
+	return imaging.Convolve3x3(img, kernel, nil)
+}
+
+// sobel returns the gradient magnitude of the grayscale image.
+func sobel(img image.Image) *image.NRGBA {
+	gray := imaging.Grayscale(img)
+	w, h := gray.Bounds().Dx(), gray.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	at := func(x, y int) float64 {
+		x = min(max(x, 0), w-1)
+		y = min(max(y, 0), h-1)
+		return float64(gray.Pix[y*gray.Stride+x*4])
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := -at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1) +
+				at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1)
+			gy := -at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1) +
+				at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1)
+			v := clampUint8(math.Hypot(gx, gy))
+
+			i := y*dst.Stride + x*4
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = v, v, v
+			dst.Pix[i+3] = gray.Pix[y*gray.Stride+x*4+3]
+		}
+	}
+	return dst
+}
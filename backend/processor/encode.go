@@ -0,0 +1,364 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+type formatInfo struct {
+	Extension   string
+	ContentType string
+}
+
+// outputFormats lists the encodable formats.
+var outputFormats = map[string]formatInfo{
+	"jpeg": {"jpg", "image/jpeg"},
+	"png":  {"png", "image/png"},
+	"gif":  {"gif", "image/gif"},
+	"tiff": {"tiff", "image/tiff"},
+	"bmp":  {"bmp", "image/bmp"},
+	"webp": {"webp", "image/webp"},
+}
+
+var formatAliases = map[string]string{
+	"jpg": "jpeg",
+	"tif": "tiff",
+}
+
+func supportedOutputFormats() string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// NormalizeFormat resolves aliases such as "jpg" and rejects formats that
+// cannot be encoded.
+func NormalizeFormat(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	if alias, ok := formatAliases[name]; ok {
+		name = alias
+	}
+	if _, ok := outputFormats[name]; !ok {
+		return "", fmt.Errorf("unsupported output format: %s (supported: %s)", name, supportedOutputFormats())
+	}
+	return name, nil
+}
+
+// ContentTypeForPath returns the content type of a processed file based on
+// its extension.
+func ContentTypeForPath(path string) string {
+	format, err := NormalizeFormat(filepath.Ext(path))
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return outputFormats[format].ContentType
+}
+
+// HasAlpha reports whether the image file at path has transparent pixels.
+// JPEG files are always opaque and are not decoded.
+func HasAlpha(path string) bool {
+	if ContentTypeForPath(path) == "image/jpeg" {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return false
+	}
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	return false
+}
+
+// outputFormat returns the explicit output_format parameter or, by default,
+// the format of the source image.
+func outputFormat(sourcePath string, params map[string]interface{}) (string, error) {
+	name, err := stringParam(params, "output_format", "")
+	if err != nil {
+		return "", err
+	}
+	if name != "" {
+		return NormalizeFormat(name)
+	}
+
+	f, err := os.Open(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect source format: %v", err)
+	}
+	if _, ok := outputFormats[format]; ok {
+		return format, nil
+	}
+	// No encoder for the source format; PNG keeps both detail and alpha
+	return "png", nil
+}
+
+// encodeImage writes img in the given (normalized) format. Format-specific
+// options are read from params:
+//   - jpeg: quality (1-100)
+//   - png:  quality, mapped to a compression level
+//   - tiff: compression ("none" or "deflate"), predictor
+//   - gif:  colors (2-256), dither
+//   - webp: always lossless
+func encodeImage(w io.Writer, img image.Image, format string, params map[string]interface{}) error {
+	switch format {
+	case "jpeg":
+		quality, err := intParam(params, "quality", 95)
+		if err != nil {
+			return err
+		}
+		if quality < 1 || quality > 100 {
+			return fmt.Errorf("quality must be between 1 and 100")
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+
+	case "png":
+		quality, err := intParam(params, "quality", 50)
+		if err != nil {
+			return err
+		}
+
+		var level int
+		if quality < 20 {
+			level = -3
+		} else if quality > 20 && quality < 80 {
+			level = 0
+		} else if quality > 80 {
+			level = -2
+		} else {
+			level = -1
+		}
+		encoder := png.Encoder{CompressionLevel: png.CompressionLevel(level)}
+		return encoder.Encode(w, img)
+
+	case "tiff":
+		compression, err := stringParam(params, "compression", "deflate")
+		if err != nil {
+			return err
+		}
+		predictor, err := boolParam(params, "predictor", true)
+		if err != nil {
+			return err
+		}
+
+		options := &tiff.Options{Predictor: predictor}
+		switch compression {
+		case "none":
+			options.Compression = tiff.Uncompressed
+		case "deflate":
+			options.Compression = tiff.Deflate
+		default:
+			return fmt.Errorf("unsupported tiff compression: %s (supported: none, deflate)", compression)
+		}
+		return tiff.Encode(w, img, options)
+
+	case "bmp":
+		return bmp.Encode(w, img)
+
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+
+	case "gif":
+		colors, drawer, err := gifOptions(params)
+		if err != nil {
+			return err
+		}
+		return gif.Encode(w, img, &gif.Options{NumColors: colors, Quantizer: medianCut{}, Drawer: drawer})
+
+	default:
+		return fmt.Errorf("unsupported output format: %s (supported: %s)", format, supportedOutputFormats())
+	}
+}
+
+// gifOptions reads the palette size and dithering used for GIF output.
+func gifOptions(params map[string]interface{}) (int, draw.Drawer, error) {
+	colors, err := intParam(params, "colors", 256)
+	if err != nil {
+		return 0, nil, err
+	}
+	dither, err := boolParam(params, "dither", true)
+	if err != nil {
+		return 0, nil, err
+	}
+	if colors < 2 || colors > 256 {
+		return 0, nil, fmt.Errorf("colors must be between 2 and 256")
+	}
+
+	if dither {
+		return colors, draw.FloydSteinberg, nil
+	}
+	return colors, draw.Src, nil
+}
+
+// saveOutput writes img next to basePath (a path without extension) in the
+// requested or source format and returns the full output path.
+func saveOutput(img image.Image, sourcePath, basePath string, params map[string]interface{}) (string, error) {
+	format, err := outputFormat(sourcePath, params)
+	if err != nil {
+		return "", err
+	}
+
+	outputPath := basePath + "." + outputFormats[format].Extension
+	if err := saveEncoded(img, sourcePath, outputPath, format, params); err != nil {
+		return "", err
+	}
+	return outputPath, nil
+}
+
+// saveEncoded encodes img to outputPath and carries over metadata from the
+// source according to the strip_metadata parameter.
+func saveEncoded(img image.Image, sourcePath, outputPath, format string, params map[string]interface{}) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := encodeImage(f, img, format, params); err != nil {
+		f.Close()
+		os.Remove(outputPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return transferMetadata(sourcePath, outputPath, params)
+}
+
+// medianCut builds an adaptive palette by recursively splitting the color
+// space at the median of its widest channel.
+type medianCut struct{}
+
+type colorBox struct {
+	pixels []color.NRGBA
+}
+
+func (b colorBox) widestChannel() (int, uint8) {
+	lo := [3]uint8{255, 255, 255}
+	hi := [3]uint8{}
+	for _, p := range b.pixels {
+		for c, v := range [3]uint8{p.R, p.G, p.B} {
+			lo[c] = min(lo[c], v)
+			hi[c] = max(hi[c], v)
+		}
+	}
+
+	channel := 0
+	for c := 1; c < 3; c++ {
+		if hi[c]-lo[c] > hi[channel]-lo[channel] {
+			channel = c
+		}
+	}
+	return channel, hi[channel] - lo[channel]
+}
+
+func (b colorBox) average() color.Color {
+	var r, g, bl int
+	for _, p := range b.pixels {
+		r += int(p.R)
+		g += int(p.G)
+		bl += int(p.B)
+	}
+	n := len(b.pixels)
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: 255}
+}
+
+func (medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	size := cap(p) - len(p)
+	bounds := m.Bounds()
+
+	// Sample at most ~64k pixels to keep large images fast
+	step := 1
+	for (bounds.Dx()/step)*(bounds.Dy()/step) > 1<<16 {
+		step++
+	}
+
+	var pixels []color.NRGBA
+	transparent := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			if c.A < 128 {
+				transparent = true
+				continue
+			}
+			pixels = append(pixels, c)
+		}
+	}
+
+	if transparent {
+		p = append(p, color.Transparent)
+		size--
+	}
+	if len(pixels) == 0 {
+		return p
+	}
+
+	boxes := []colorBox{{pixels: pixels}}
+	for len(boxes) < size {
+		// Split the box with the widest channel range
+		best, bestRange, bestChannel := -1, uint8(0), 0
+		for i, b := range boxes {
+			if len(b.pixels) < 2 {
+				continue
+			}
+			if channel, r := b.widestChannel(); r > bestRange {
+				best, bestRange, bestChannel = i, r, channel
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		b := boxes[best]
+		sort.Slice(b.pixels, func(i, j int) bool {
+			return channelValue(b.pixels[i], bestChannel) < channelValue(b.pixels[j], bestChannel)
+		})
+		mid := len(b.pixels) / 2
+		boxes[best] = colorBox{pixels: b.pixels[:mid]}
+		boxes = append(boxes, colorBox{pixels: b.pixels[mid:]})
+	}
+
+	for _, b := range boxes {
+		p = append(p, b.average())
+	}
+	return p
+}
+
+func channelValue(c color.NRGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	default:
+		return c.B
+	}
+}
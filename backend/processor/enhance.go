@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+// targetStdDev is the luminance spread contrast normalization aims for.
+const targetStdDev = 55.0
+
+// AutoEnhance applies gray-world white balance, levels stretching and
+// contrast normalization computed from the image histogram. The computed
+// corrections are returned so callers can record and reproduce them.
+func AutoEnhance(db *sql.DB, imageID string, params map[string]interface{}) (string, map[string]interface{}, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", nil, err
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open image: %v", err)
+	}
+
+	whiteBalance, err := boolParam(params, "white_balance", true)
+	if err != nil {
+		return "", nil, err
+	}
+	levels, err := boolParam(params, "levels", true)
+	if err != nil {
+		return "", nil, err
+	}
+	contrast, err := boolParam(params, "contrast", true)
+	if err != nil {
+		return "", nil, err
+	}
+	clip, err := floatParam(params, "clip", 0.5)
+	if err != nil {
+		return "", nil, err
+	}
+	if clip < 0 || clip >= 50 {
+		return "", nil, fmt.Errorf("clip must be between 0 and 50 percent")
+	}
+
+	src := imaging.Clone(img)
+	corrections := map[string]interface{}{}
+
+	// Per-channel lookup tables; each step composes onto the previous one
+	var curves [3][256]float64
+	for c := range curves {
+		for v := range curves[c] {
+			curves[c][v] = float64(v)
+		}
+	}
+
+	if whiteBalance {
+		var sums [3]float64
+		count := 0.0
+		for i := 0; i < len(src.Pix); i += 4 {
+			if src.Pix[i+3] == 0 {
+				continue
+			}
+			sums[0] += float64(src.Pix[i])
+			sums[1] += float64(src.Pix[i+1])
+			sums[2] += float64(src.Pix[i+2])
+			count++
+		}
+
+		if count > 0 && sums[0] > 0 && sums[1] > 0 && sums[2] > 0 {
+			gray := (sums[0] + sums[1] + sums[2]) / 3
+			var gains [3]float64
+			for c := range gains {
+				gains[c] = math.Min(math.Max(gray/sums[c], 0.5), 2)
+				for v := range curves[c] {
+					curves[c][v] *= gains[c]
+				}
+			}
+			corrections["white_balance"] = map[string]interface{}{
+				"r_gain": round3(gains[0]),
+				"g_gain": round3(gains[1]),
+				"b_gain": round3(gains[2]),
+			}
+		}
+	}
+
+	// Luminance histogram of the image with the corrections so far
+	histogram := func() ([256]float64, float64) {
+		var hist [256]float64
+		total := 0.0
+		for i := 0; i < len(src.Pix); i += 4 {
+			if src.Pix[i+3] == 0 {
+				continue
+			}
+			r := curves[0][src.Pix[i]]
+			g := curves[1][src.Pix[i+1]]
+			b := curves[2][src.Pix[i+2]]
+			hist[clampUint8(0.299*r+0.587*g+0.114*b)]++
+			total++
+		}
+		return hist, total
+	}
+
+	if levels {
+		hist, total := histogram()
+		if total > 0 {
+			limit := total * clip / 100
+			black, white := 0, 255
+			for acc := 0.0; black < 255; black++ {
+				if acc += hist[black]; acc > limit {
+					break
+				}
+			}
+			for acc := 0.0; white > 0; white-- {
+				if acc += hist[white]; acc > limit {
+					break
+				}
+			}
+
+			if white > black {
+				scale := 255 / float64(white-black)
+				for c := range curves {
+					for v := range curves[c] {
+						curves[c][v] = (curves[c][v] - float64(black)) * scale
+					}
+				}
+				corrections["levels"] = map[string]interface{}{
+					"black_point": black,
+					"white_point": white,
+				}
+			}
+		}
+	}
+
+	if contrast {
+		hist, total := histogram()
+		if total > 0 {
+			mean, variance := 0.0, 0.0
+			for v, n := range hist {
+				mean += float64(v) * n
+			}
+			mean /= total
+			for v, n := range hist {
+				variance += (float64(v) - mean) * (float64(v) - mean) * n
+			}
+			stddev := math.Sqrt(variance / total)
+
+			if stddev > 0 {
+				factor := math.Min(math.Max(targetStdDev/stddev, 0.8), 1.5)
+				for c := range curves {
+					for v := range curves[c] {
+						curves[c][v] = mean + (curves[c][v]-mean)*factor
+					}
+				}
+				corrections["contrast"] = map[string]interface{}{
+					"factor": round3(factor),
+					"mean":   round3(mean),
+				}
+			}
+		}
+	}
+
+	var lut [3][256]uint8
+	for c := range curves {
+		for v := range curves[c] {
+			lut[c][v] = clampUint8(curves[c][v])
+		}
+	}
+	enhanced := imaging.AdjustFunc(src, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{R: lut[0][c.R], G: lut[1][c.G], B: lut[2][c.B], A: c.A}
+	})
+
+	outputPath := scratchPath(params, fmt.Sprintf("%s_auto_enhance", imageID))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	outputPath, err = saveOutput(enhanced, originalPath, outputPath, params)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save enhanced image: %v", err)
+	}
+
+	return outputPath, corrections, nil
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
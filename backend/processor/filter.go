@@ -3,48 +3,68 @@ package processor
 import (
 	"database/sql"
 	"fmt"
-	"github.com/disintegration/imaging"
 	"image"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
 )
 
 func ApplyFilter(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
-	var originalPath string
-	err := db.QueryRow("SELECT original_path FROM images WHERE id = $1", imageID).Scan(&originalPath)
+	originalPath, err := sourcePath(db, imageID, params)
 	if err != nil {
-		return "", fmt.Errorf("image not found: %v", err)
+		return "", err
 	}
 
-	img, err := imaging.Open(originalPath)
+	filterType, err := stringParam(params, "filter_type", "")
 	if err != nil {
-		return "", fmt.Errorf("failed to open image: %v", err)
+		return "", err
 	}
 
-	filterType := params["filter_type"].(string)
-	intensity := params["intensity"].(float64)
-
-	var filtered image.Image
-
-	// CPU-intensive operations
-	switch filterType {
-	case "blur":
-		filtered = imaging.Blur(img, intensity)
-	case "sharpen":
-		filtered = imaging.Sharpen(img, intensity)
-	case "grayscale":
-		filtered = imaging.Grayscale(img)
-	default:
-		return "", fmt.Errorf("unknown filter type: %s", filterType)
-	}
+	// CPU-intensive operations, applied to every frame of an animation
+	filter := func(img image.Image) (image.Image, error) {
+		switch filterType {
+		case "blur", "sharpen":
+			// For blur and sharpen the intensity is the sigma of the kernel
+			sigma, err := floatParam(params, "intensity", 0)
+			if err != nil {
+				return nil, err
+			}
+			if !(sigma >= 0 && sigma <= maxBlurSigma) {
+				return nil, fmt.Errorf("intensity must be between 0 and %d for %s", maxBlurSigma, filterType)
+			}
+			if filterType == "blur" {
+				return imaging.Blur(img, sigma), nil
+			}
+			return imaging.Sharpen(img, sigma), nil
+		case "grayscale":
+			return imaging.Grayscale(img), nil
+		default:
+			effect, ok := effects[filterType]
+			if !ok {
+				return nil, fmt.Errorf("unknown filter type: %s (supported: %s)", filterType, strings.Join(filterNames(), ", "))
+			}
 
-	outputPath := filepath.Join(storageBasePath, fmt.Sprintf("%s_filter_%s.jpg", imageID, filterType))
-	os.MkdirAll(filepath.Dir(outputPath), 0755)
+			// For effects the intensity is a 0-1 strength blending with the original
+			strength, err := floatParam(params, "intensity", 1)
+			if err != nil {
+				return nil, err
+			}
+			if strength < 0 || strength > 1 {
+				return nil, fmt.Errorf("intensity must be between 0 and 1 for %s", filterType)
+			}
 
-	err = imaging.Save(filtered, outputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to save filtered image: %v", err)
+			result, err := effect(img, params)
+			if err != nil {
+				return nil, err
+			}
+			return blend(img, result, strength), nil
+		}
 	}
 
-	return outputPath, nil
+	outputPath := scratchPath(params, fmt.Sprintf("%s_filter_%s", imageID, filterType))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	return processImage(originalPath, outputPath, params, filter)
 }
@@ -0,0 +1,9 @@
+package processor
+
+// Register decoders beyond the standard library so imaging.Open accepts
+// WebP, TIFF and BMP uploads.
+import (
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/amandeep2102/image-processor/shared/lut"
+	"github.com/disintegration/imaging"
+)
+
+// ApplyLUT color-grades an image with a named .cube LUT uploaded through the
+// frontend, blended with the original by "strength" (0-1).
+func ApplyLUT(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", err
+	}
+
+	name, err := stringParam(params, "lut", "")
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("lut is required")
+	}
+
+	strength, err := floatParam(params, "strength", 1)
+	if err != nil {
+		return "", err
+	}
+	if strength < 0 || strength > 1 {
+		return "", fmt.Errorf("strength must be between 0 and 1")
+	}
+
+	var lutKey string
+	err = db.QueryRow("SELECT path FROM luts WHERE name = $1", name).Scan(&lutKey)
+	if err != nil {
+		return "", fmt.Errorf("lut not found: %v", err)
+	}
+
+	table, err := loadLUT(lutKey)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+
+	// Map pixels into the table's domain, look them up, and map back
+	scale := func(v uint8, c int) float64 {
+		return table.DomainMin[c] + float64(v)/255*(table.DomainMax[c]-table.DomainMin[c])
+	}
+	graded := imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, g, b := table.Apply(scale(c.R, 0), scale(c.G, 1), scale(c.B, 2))
+		return color.NRGBA{R: clampUint8(r * 255), G: clampUint8(g * 255), B: clampUint8(b * 255), A: c.A}
+	})
+
+	outputPath := scratchPath(params, fmt.Sprintf("%s_lut_%s_%g", imageID, name, strength))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	outputPath, err = saveOutput(blend(img, graded, strength), originalPath, outputPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to save graded image: %v", err)
+	}
+
+	return outputPath, nil
+}
+
+// Parsed LUTs are cached by storage key and reused while the stored file is
+// unchanged, so a job only pays for a stat instead of parsing the table.
+const maxCachedLUTs = 32
+
+type cachedLUT struct {
+	modTime time.Time
+	table   *lut.LUT
+}
+
+var (
+	lutCacheMu sync.Mutex
+	lutCache   = map[string]cachedLUT{}
+)
+
+func loadLUT(key string) (*lut.LUT, error) {
+	info, err := Files.Stat(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lut: %v", err)
+	}
+
+	lutCacheMu.Lock()
+	cached, ok := lutCache[key]
+	lutCacheMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime) {
+		return cached.table, nil
+	}
+
+	f, err := Files.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lut: %v", err)
+	}
+	table, err := lut.Parse(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lut: %v", err)
+	}
+
+	lutCacheMu.Lock()
+	defer lutCacheMu.Unlock()
+	if _, ok := lutCache[key]; !ok && len(lutCache) >= maxCachedLUTs {
+		// Evict an arbitrary entry; the table count is small in practice
+		for k := range lutCache {
+			delete(lutCache, k)
+			break
+		}
+	}
+	lutCache[key] = cachedLUT{modTime: info.ModTime, table: table}
+	return table, nil
+}
@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/amandeep2102/image-processor/shared/metadata"
+	"github.com/disintegration/imaging"
+)
+
+// Every operation accepts "strip_metadata" (default true). When stripping,
+// the EXIF orientation is baked into the pixels and only the color profile
+// is copied to the output; otherwise the source metadata is carried over.
+
+func openImage(path string, params map[string]interface{}) (image.Image, error) {
+	strip, err := boolParam(params, "strip_metadata", true)
+	if err != nil {
+		return nil, err
+	}
+	return imaging.Open(path, imaging.AutoOrientation(strip))
+}
+
+// transferMetadata copies the color profile (and, unless stripping, the rest
+// of the metadata) from the source file into an already written output.
+func transferMetadata(sourcePath, outputPath string, params map[string]interface{}) error {
+	strip, err := boolParam(params, "strip_metadata", true)
+	if err != nil {
+		return err
+	}
+
+	source, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read source metadata: %v", err)
+	}
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		return err
+	}
+
+	merged, err := metadata.Transfer(source, output, !strip)
+	if err != nil {
+		return fmt.Errorf("failed to copy metadata: %v", err)
+	}
+	return os.WriteFile(outputPath, merged, 0644)
+}
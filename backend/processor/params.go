@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Parameters arrive either from JSON (numbers as float64) or from handlers
+// that build the map directly (numbers as int), so the helpers accept both.
+
+func intParam(params map[string]interface{}, key string, def int) (int, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case float64:
+		return int(v), nil
+	case int:
+		return v, nil
+	default:
+		return 0, fmt.Errorf("invalid %s type: %T", key, v)
+	}
+}
+
+func floatParam(params map[string]interface{}, key string, def float64) (float64, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("invalid %s type: %T", key, v)
+	}
+}
+
+func stringParam(params map[string]interface{}, key string, def string) (string, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case string:
+		if v == "" {
+			return def, nil
+		}
+		return v, nil
+	default:
+		return "", fmt.Errorf("invalid %s type: %T", key, v)
+	}
+}
+
+func boolParam(params map[string]interface{}, key string, def bool) (bool, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case bool:
+		return v, nil
+	default:
+		return false, fmt.Errorf("invalid %s type: %T", key, v)
+	}
+}
+
+// colorParam parses "#RGB", "#RRGGBB" or "#RRGGBBAA" hex colors.
+func colorParam(params map[string]interface{}, key string, def color.NRGBA) (color.NRGBA, error) {
+	s, err := stringParam(params, key, "")
+	if err != nil || s == "" {
+		return def, err
+	}
+	return parseColor(s)
+}
+
+func parseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("invalid color: %s", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// intListParam accepts []int from handlers and []interface{} from JSON.
+func intListParam(params map[string]interface{}, key string, def []int) ([]int, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case []int:
+		return v, nil
+	case []interface{}:
+		list := make([]int, len(v))
+		for i, item := range v {
+			n, ok := item.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s item type: %T", key, item)
+			}
+			list[i] = int(n)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("invalid %s type: %T", key, v)
+	}
+}
+
+// stringListParam accepts []string from handlers and []interface{} from JSON.
+func stringListParam(params map[string]interface{}, key string, def []string) ([]string, error) {
+	switch v := params[key].(type) {
+	case nil:
+		return def, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		list := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s item type: %T", key, item)
+			}
+			list[i] = s
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("invalid %s type: %T", key, v)
+	}
+}
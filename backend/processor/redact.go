@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/disintegration/imaging"
+)
+
+const maxRedactRegions = 100
+
+// imaging.Blur allocates a kernel of 6*sigma+1 weights and reads that far
+// around every pixel, so the sigma of blur kernels is capped.
+const maxBlurSigma = 100
+
+// Region is an area to redact, see models.Region.
+type Region models.Region
+
+// ValidateRegions checks region shapes independently of any image.
+func ValidateRegions(regions []Region) error {
+	if len(regions) == 0 {
+		return fmt.Errorf("at least one region is required")
+	}
+	if len(regions) > maxRedactRegions {
+		return fmt.Errorf("at most %d regions are allowed", maxRedactRegions)
+	}
+	for i, r := range regions {
+		if r.Points != nil {
+			if len(r.Points) < 3 {
+				return fmt.Errorf("region %d: a polygon needs at least 3 points", i)
+			}
+			continue
+		}
+		if r.Width <= 0 || r.Height <= 0 {
+			return fmt.Errorf("region %d: width and height must be positive", i)
+		}
+	}
+	return nil
+}
+
+// bounds returns the bounding box of the region.
+func (r Region) bounds() image.Rectangle {
+	if r.Points == nil {
+		return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+	}
+	var box image.Rectangle
+	for _, p := range r.Points {
+		box = box.Union(image.Rect(p[0], p[1], p[0]+1, p[1]+1))
+	}
+	return box
+}
+
+// contains reports whether the center of pixel (x, y) lies inside the region.
+func (r Region) contains(x, y int) bool {
+	if r.Points == nil {
+		return image.Pt(x, y).In(r.bounds())
+	}
+
+	// Even-odd rule
+	px, py := float64(x)+0.5, float64(y)+0.5
+	inside := false
+	for i, j := 0, len(r.Points)-1; i < len(r.Points); j, i = i, i+1 {
+		xi, yi := float64(r.Points[i][0]), float64(r.Points[i][1])
+		xj, yj := float64(r.Points[j][0]), float64(r.Points[j][1])
+		if (yi > py) != (yj > py) && px < (xj-xi)*(py-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func regionsParam(params map[string]interface{}) ([]Region, error) {
+	// Regions come either typed from the handler or as decoded JSON
+	encoded, err := json.Marshal(params["regions"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid regions: %v", err)
+	}
+	var regions []Region
+	if err := json.Unmarshal(encoded, &regions); err != nil {
+		return nil, fmt.Errorf("invalid regions: %v", err)
+	}
+	return regions, nil
+}
+
+// Redact obscures the given regions with pixelation, gaussian blur or a
+// solid fill, leaving the rest of the image untouched. Metadata is always
+// stripped: EXIF thumbnails and XMP previews show the unredacted image.
+func Redact(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	params["strip_metadata"] = true
+
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", err
+	}
+
+	regions, err := regionsParam(params)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateRegions(regions); err != nil {
+		return "", err
+	}
+
+	mode, err := stringParam(params, "mode", "pixelate")
+	if err != nil {
+		return "", err
+	}
+	blockSize, err := intParam(params, "block_size", 16)
+	if err != nil {
+		return "", err
+	}
+	sigma, err := floatParam(params, "sigma", 12)
+	if err != nil {
+		return "", err
+	}
+	fill, err := colorParam(params, "color", color.NRGBA{0, 0, 0, 255})
+	if err != nil {
+		return "", err
+	}
+
+	if blockSize < 2 {
+		return "", fmt.Errorf("block_size must be at least 2")
+	}
+	if !(sigma > 0 && sigma <= maxBlurSigma) {
+		return "", fmt.Errorf("sigma must be greater than 0 and at most %d", maxBlurSigma)
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+	dst := imaging.Clone(img)
+
+	for i, region := range regions {
+		box := region.bounds().Intersect(dst.Bounds())
+		if box.Empty() {
+			return "", fmt.Errorf("region %d is outside the image", i)
+		}
+
+		// Work on the region's bounding box; blur reads a margin around it
+		// so the edges fade like the interior.
+		var patch *image.NRGBA
+		var origin image.Point
+		switch mode {
+		case "pixelate":
+			area := imaging.Crop(dst, box)
+			w, h := box.Dx(), box.Dy()
+			small := imaging.Resize(area, (w+blockSize-1)/blockSize, (h+blockSize-1)/blockSize, imaging.Box)
+			patch, origin = imaging.Resize(small, w, h, imaging.NearestNeighbor), box.Min
+		case "blur":
+			pad := int(math.Ceil(sigma * 3))
+			area := box.Inset(-pad).Intersect(dst.Bounds())
+			patch, origin = imaging.Blur(imaging.Crop(dst, area), sigma), area.Min
+		case "fill":
+			patch, origin = imaging.New(box.Dx(), box.Dy(), fill), box.Min
+		default:
+			return "", fmt.Errorf("unknown redaction mode: %s (supported: pixelate, blur, fill)", mode)
+		}
+
+		for y := box.Min.Y; y < box.Max.Y; y++ {
+			for x := box.Min.X; x < box.Max.X; x++ {
+				if !region.contains(x, y) {
+					continue
+				}
+				si := (y-origin.Y)*patch.Stride + (x-origin.X)*4
+				di := y*dst.Stride + x*4
+				copy(dst.Pix[di:di+4], patch.Pix[si:si+4])
+			}
+		}
+	}
+
+	outputPath := scratchPath(params, fmt.Sprintf("%s_redact_%s", imageID, mode))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	outputPath, err = saveOutput(dst, originalPath, outputPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to save redacted image: %v", err)
+	}
+
+	return outputPath, nil
+}
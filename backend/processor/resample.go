@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+var resampleFilters = map[string]imaging.ResampleFilter{
+	"nearest_neighbor":   imaging.NearestNeighbor,
+	"box":                imaging.Box,
+	"linear":             imaging.Linear,
+	"hermite":            imaging.Hermite,
+	"mitchell_netravali": imaging.MitchellNetravali,
+	"catmull_rom":        imaging.CatmullRom,
+	"bspline":            imaging.BSpline,
+	"gaussian":           imaging.Gaussian,
+	"bartlett":           imaging.Bartlett,
+	"lanczos":            imaging.Lanczos,
+	"hann":               imaging.Hann,
+	"hamming":            imaging.Hamming,
+	"blackman":           imaging.Blackman,
+	"welch":              imaging.Welch,
+	"cosine":             imaging.Cosine,
+}
+
+// resamplePresets trade quality for speed, from fastest to best
+var resamplePresets = map[string]string{
+	"fastest":  "nearest_neighbor",
+	"fast":     "linear",
+	"balanced": "catmull_rom",
+	"quality":  "lanczos",
+}
+
+const defaultResample = "lanczos"
+
+// ResolveResample maps a filter or preset name to the filter it selects.
+// An empty name selects the default filter.
+func ResolveResample(name string) (string, error) {
+	name = strings.ToLower(name)
+	if name == "" {
+		return defaultResample, nil
+	}
+	if filter, ok := resamplePresets[name]; ok {
+		return filter, nil
+	}
+	if _, ok := resampleFilters[name]; ok {
+		return name, nil
+	}
+
+	names := make([]string, 0, len(resampleFilters)+len(resamplePresets))
+	for filter := range resampleFilters {
+		names = append(names, filter)
+	}
+	for preset := range resamplePresets {
+		names = append(names, preset)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown resample filter: %s (supported: %s)", name, strings.Join(names, ", "))
+}
+
+func resampleParam(params map[string]interface{}) (imaging.ResampleFilter, error) {
+	name, err := stringParam(params, "resample", "")
+	if err != nil {
+		return imaging.ResampleFilter{}, err
+	}
+	filter, err := ResolveResample(name)
+	if err != nil {
+		return imaging.ResampleFilter{}, err
+	}
+	return resampleFilters[filter], nil
+}
@@ -3,62 +3,137 @@ package processor
 import (
 	"database/sql"
 	"fmt"
+	"image"
+	"image/color"
+	"math"
 	"os"
 	"path/filepath"
 
 	"github.com/disintegration/imaging"
 )
 
-const storageBasePath = "/home/polarbeer/Documents/Image-Processor/backend/storage/processed"
+// StorageBasePath is local scratch space where processors write their output
+// before it is moved into storage. Set from the configuration.
+var StorageBasePath string
+
+// Resize modes, used when both width and height are given:
+//   - fit:   scale to fit within the box, keeping the aspect ratio
+//   - fill:  scale to cover the box and crop the overflow at position
+//   - exact: stretch to exactly width x height (default)
+//   - pad:   fit within the box, then pad to its size with background
+//
+// When only one dimension is given the other follows the aspect ratio.
+// allow_upscale=false never makes the image larger than the source. The
+// resample parameter picks the interpolation filter (see resample.go).
+var resizeModes = map[string]bool{"fit": true, "fill": true, "exact": true, "pad": true}
+
+type resizeOptions struct {
+	mode       string
+	upscale    bool
+	background color.NRGBA
+	anchor     imaging.Anchor
+	filter     imaging.ResampleFilter
+}
 
 func Resize(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
 	// Get original image path
-	var originalPath string
-	err := db.QueryRow("SELECT original_path FROM images WHERE id = $1", imageID).Scan(&originalPath)
+	originalPath, err := sourcePath(db, imageID, params)
 	if err != nil {
-		return "", fmt.Errorf("image not found: %v", err)
+		return "", err
 	}
 
-	// Open image
-	img, err := imaging.Open(originalPath)
+	// Get resize parameters
+	width, err := intParam(params, "width", 0)
 	if err != nil {
-		return "", fmt.Errorf("failed to open image: %v", err)
+		return "", err
 	}
-
-	// Get resize parameters
-	// width := int(params["width"].(float64))
-	// height := int(params["height"].(float64))
-	var width, height int
-
-	switch v := params["width"].(type) {
-	case float64:
-		width = int(v)
-	case int:
-		width = v
-	default:
-		return "", fmt.Errorf("invalid width type %T", v)
+	height, err := intParam(params, "height", 0)
+	if err != nil {
+		return "", err
+	}
+	if width < 0 || height < 0 || width == 0 && height == 0 {
+		return "", fmt.Errorf("width or height must be positive")
 	}
 
-	switch v := params["height"].(type) {
-	case float64:
-		height = int(v)
-	case int:
-		height = v
-	default:
-		return "", fmt.Errorf("invalid height type %T", v)
+	var opts resizeOptions
+	if opts.mode, err = stringParam(params, "mode", "exact"); err != nil {
+		return "", err
+	}
+	if !resizeModes[opts.mode] {
+		return "", fmt.Errorf("unknown resize mode: %s (supported: exact, fill, fit, pad)", opts.mode)
+	}
+	if opts.upscale, err = boolParam(params, "allow_upscale", true); err != nil {
+		return "", err
+	}
+	if opts.background, err = colorParam(params, "background", color.NRGBA{255, 255, 255, 255}); err != nil {
+		return "", err
+	}
+	position, err := stringParam(params, "position", "center")
+	if err != nil {
+		return "", err
+	}
+	if opts.anchor, err = parseAnchor(position); err != nil {
+		return "", err
+	}
+	if opts.filter, err = resampleParam(params); err != nil {
+		return "", err
 	}
 
 	// Resize image (CPU-intensive operation)
-	resized := imaging.Resize(img, width, height, imaging.Lanczos)
+	resize := func(img image.Image) (image.Image, error) {
+		return resizeImage(img, width, height, opts), nil
+	}
 
 	// Save processed image
-	outputPath := filepath.Join(storageBasePath, fmt.Sprintf("%s_resized_%dx%d.jpg", imageID, width, height))
+	outputPath := scratchPath(params, fmt.Sprintf("%s_resized_%dx%d_%s", imageID, width, height, opts.mode))
 	os.MkdirAll(filepath.Dir(outputPath), 0755)
 
-	err = imaging.Save(resized, outputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to save image: %v", err)
+	return processImage(originalPath, outputPath, params, resize)
+}
+
+func resizeImage(img image.Image, width, height int, opts resizeOptions) image.Image {
+	src := img.Bounds().Size()
+	scaleX := float64(width) / float64(src.X)
+	scaleY := float64(height) / float64(src.Y)
+
+	// A single dimension scales uniformly, whatever the mode
+	if width == 0 || height == 0 {
+		scale := math.Max(scaleX, scaleY)
+		if !opts.upscale && scale > 1 {
+			return imaging.Clone(img)
+		}
+		return imaging.Resize(img, width, height, opts.filter)
 	}
 
-	return outputPath, nil
+	switch opts.mode {
+	case "exact":
+		if !opts.upscale {
+			width, height = min(width, src.X), min(height, src.Y)
+		}
+		return imaging.Resize(img, width, height, opts.filter)
+
+	case "fill":
+		// Without upscaling, shrink the box (keeping its shape) to what the source covers
+		if scale := math.Max(scaleX, scaleY); !opts.upscale && scale > 1 {
+			width = max(1, int(math.Round(float64(width)/scale)))
+			height = max(1, int(math.Round(float64(height)/scale)))
+		}
+		return imaging.Fill(img, width, height, opts.anchor, opts.filter)
+
+	default:
+		fitted := img
+		if scale := math.Min(scaleX, scaleY); opts.upscale || scale < 1 {
+			fitted = imaging.Resize(img,
+				max(1, int(math.Round(float64(src.X)*scale))),
+				max(1, int(math.Round(float64(src.Y)*scale))),
+				opts.filter)
+		}
+		if opts.mode != "pad" {
+			return fitted
+		}
+
+		canvas := imaging.New(width, height, opts.background)
+		pos := anchorPoint(canvas.Bounds(), fitted.Bounds().Size(), opts.anchor, 0)
+		return imaging.Paste(canvas, fitted, pos)
+	}
 }
@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+var defaultResponsiveWidths = []int{320, 640, 960, 1280, 1920}
+
+// Variant is one output of the responsive operation.
+type Variant struct {
+	Path   string
+	Width  int
+	Height int
+	Format string
+}
+
+// Responsive renders the image at each of the requested widths in each of
+// the requested formats. Widths wider than the source are skipped unless
+// allow_upscale is set; the source width is used if none remain.
+func Responsive(db *sql.DB, imageID string, params map[string]interface{}) ([]Variant, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return nil, err
+	}
+
+	widths, err := intListParam(params, "widths", defaultResponsiveWidths)
+	if err != nil {
+		return nil, err
+	}
+	formats, err := stringListParam(params, "formats", nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(formats) == 0 {
+		format, err := outputFormat(originalPath, params)
+		if err != nil {
+			return nil, err
+		}
+		formats = []string{format}
+	}
+	var normalized []string
+	for _, name := range formats {
+		format, err := NormalizeFormat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(normalized, format) {
+			normalized = append(normalized, format)
+		}
+	}
+	formats = normalized
+	upscale, err := boolParam(params, "allow_upscale", false)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := resampleParam(params)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %v", err)
+	}
+	sourceWidth := img.Bounds().Dx()
+
+	seen := map[int]bool{}
+	var selected []int
+	for _, width := range widths {
+		if width <= 0 {
+			return nil, fmt.Errorf("widths must be positive")
+		}
+		if !upscale && width > sourceWidth {
+			continue
+		}
+		if !seen[width] {
+			seen[width] = true
+			selected = append(selected, width)
+		}
+	}
+	if len(selected) == 0 {
+		selected = []int{sourceWidth}
+	}
+	sort.Ints(selected)
+
+	os.MkdirAll(StorageBasePath, 0755)
+
+	var variants []Variant
+	for _, width := range selected {
+		resized := imaging.Resize(img, width, 0, filter)
+		for _, format := range formats {
+			outputPath := scratchPath(params,
+				fmt.Sprintf("%s_responsive_%dw.%s", imageID, width, outputFormats[format].Extension))
+			if err := saveEncoded(resized, originalPath, outputPath, format, params); err != nil {
+				return nil, fmt.Errorf("failed to save image: %v", err)
+			}
+
+			variants = append(variants, Variant{
+				Path:   outputPath,
+				Width:  width,
+				Height: resized.Bounds().Dy(),
+				Format: format,
+			})
+		}
+	}
+	return variants, nil
+}
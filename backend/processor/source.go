@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+
+	"github.com/amandeep2102/image-processor/shared/storage"
+)
+
+// Files is the storage holding originals, processed images and LUTs. Set
+// from the configuration.
+var Files storage.Blob
+
+// LocalFile returns a local path for a stored key, downloading it from
+// remote storage if needed.
+func LocalFile(key string) (string, error) {
+	return storage.LocalFile(Files, key, filepath.Join(StorageBasePath, "cache"))
+}
+
+// sourcePath returns the file an operation reads. Pipelines set the
+// source_path parameter to the output of the previous step; otherwise the
+// original upload is used.
+func sourcePath(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	if path, ok := params["source_path"].(string); ok && path != "" {
+		return path, nil
+	}
+
+	var originalKey string
+	err := db.QueryRow("SELECT original_path FROM images WHERE id = $1", imageID).Scan(&originalKey)
+	if err != nil {
+		return "", fmt.Errorf("image not found: %v", err)
+	}
+
+	path, err := LocalFile(originalKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch image: %v", err)
+	}
+	return path, nil
+}
+
+// scratchPath returns the local path an operation writes its output to. The
+// worker sets the job_id parameter so that jobs on the same image with the
+// same settings never write to the same file.
+func scratchPath(params map[string]interface{}, name string) string {
+	if jobID, ok := params["job_id"].(string); ok && jobID != "" {
+		name = jobID + "_" + name
+	}
+	return filepath.Join(StorageBasePath, name)
+}
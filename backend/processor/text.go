@@ -0,0 +1,260 @@
+package processor
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Bundled Go fonts, so rendering does not depend on fonts installed on the host
+var fonts = map[string][]byte{
+	"regular": goregular.TTF,
+	"bold":    gobold.TTF,
+	"italic":  goitalic.TTF,
+	"mono":    gomono.TTF,
+}
+
+// Rendering cost grows with the glyph size and the stroke, which is drawn by
+// offsetting the text in every direction, so both are capped. The text layer
+// is allocated in full before drawing, so its size is limited too.
+const (
+	maxFontSize     = 1000
+	maxStrokeWidth  = 50
+	maxShadowOffset = 100
+	maxTextLength   = 2000
+	maxTextLines    = 100
+	maxTextPixels   = 50_000_000
+)
+
+func Text(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", err
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+
+	text, err := stringParam(params, "text", "")
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("text is required")
+	}
+	if len(text) > maxTextLength {
+		return "", fmt.Errorf("text must be at most %d bytes", maxTextLength)
+	}
+
+	fontName, err := stringParam(params, "font", "regular")
+	if err != nil {
+		return "", err
+	}
+	fontData, ok := fonts[fontName]
+	if !ok {
+		return "", fmt.Errorf("unknown font: %s", fontName)
+	}
+
+	fontSize, err := floatParam(params, "font_size", 32)
+	if err != nil {
+		return "", err
+	}
+	if fontSize <= 0 || fontSize > maxFontSize {
+		return "", fmt.Errorf("font_size must be between 0 and %d", maxFontSize)
+	}
+
+	textColor, err := colorParam(params, "color", color.NRGBA{255, 255, 255, 255})
+	if err != nil {
+		return "", err
+	}
+	strokeColor, err := colorParam(params, "stroke_color", color.NRGBA{0, 0, 0, 255})
+	if err != nil {
+		return "", err
+	}
+	strokeWidth, err := intParam(params, "stroke_width", 0)
+	if err != nil {
+		return "", err
+	}
+	shadowColor, err := colorParam(params, "shadow_color", color.NRGBA{0, 0, 0, 128})
+	if err != nil {
+		return "", err
+	}
+	shadowOffset, err := intParam(params, "shadow_offset", 0)
+	if err != nil {
+		return "", err
+	}
+
+	align, err := stringParam(params, "align", "left")
+	if err != nil {
+		return "", err
+	}
+	if align != "left" && align != "center" && align != "right" {
+		return "", fmt.Errorf("unknown align: %s", align)
+	}
+
+	position, err := stringParam(params, "position", "bottom_right")
+	if err != nil {
+		return "", err
+	}
+	anchor, err := parseAnchor(position)
+	if err != nil {
+		return "", err
+	}
+	margin, err := intParam(params, "margin", 10)
+	if err != nil {
+		return "", err
+	}
+
+	bounds := img.Bounds()
+	boxWidth, err := intParam(params, "box_width", bounds.Dx()-2*margin)
+	if err != nil {
+		return "", err
+	}
+
+	if strokeWidth < 0 || shadowOffset < 0 || margin < 0 {
+		return "", fmt.Errorf("stroke_width, shadow_offset and margin must not be negative")
+	}
+	if strokeWidth > maxStrokeWidth {
+		return "", fmt.Errorf("stroke_width must be at most %d", maxStrokeWidth)
+	}
+	if shadowOffset > maxShadowOffset {
+		return "", fmt.Errorf("shadow_offset must be at most %d", maxShadowOffset)
+	}
+
+	parsed, err := opentype.Parse(fontData)
+	if err != nil {
+		return "", fmt.Errorf("failed to load font: %v", err)
+	}
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{
+		Size:    fontSize,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to load font: %v", err)
+	}
+	defer face.Close()
+
+	lines := wrapText(face, text, boxWidth)
+	if len(lines) > maxTextLines {
+		return "", fmt.Errorf("text wraps to %d lines, the limit is %d", len(lines), maxTextLines)
+	}
+
+	// The layer is padded for stroke and shadow; keep the glyphs themselves at the margin
+	pad := strokeWidth + shadowOffset
+	_, blockWidth := measureLines(face, lines)
+	layerWidth, layerHeight := blockWidth+2*pad, face.Metrics().Height.Ceil()*len(lines)+2*pad
+	if layerWidth*layerHeight > maxTextPixels {
+		return "", fmt.Errorf("text of %dx%d pixels exceeds the limit of %d pixels", layerWidth, layerHeight, maxTextPixels)
+	}
+
+	layer := renderText(face, lines, align, textColor, strokeColor, strokeWidth, shadowColor, shadowOffset)
+	pos := anchorPoint(bounds.Inset(margin-pad), layer.Bounds().Size(), anchor, 0)
+	captioned := imaging.Overlay(img, layer, pos, 1.0)
+
+	hash := sha1.Sum([]byte(text + position))
+	outputPath := scratchPath(params, fmt.Sprintf("%s_text_%x", imageID, hash[:4]))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	outputPath, err = saveOutput(captioned, originalPath, outputPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to save image: %v", err)
+	}
+
+	return outputPath, nil
+}
+
+// wrapText splits text into lines no wider than maxWidth, breaking at spaces.
+// Explicit newlines always start a new line.
+func wrapText(face font.Face, text string, maxWidth int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		words := strings.Fields(paragraph)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+
+		line := words[0]
+		for _, word := range words[1:] {
+			candidate := line + " " + word
+			if maxWidth > 0 && font.MeasureString(face, candidate).Ceil() > maxWidth {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// measureLines returns the width of each line and of the widest one.
+func measureLines(face font.Face, lines []string) ([]int, int) {
+	widths := make([]int, len(lines))
+	blockWidth := 0
+	for i, line := range lines {
+		widths[i] = font.MeasureString(face, line).Ceil()
+		blockWidth = max(blockWidth, widths[i])
+	}
+	return widths, blockWidth
+}
+
+// renderText draws the lines onto a transparent layer sized to fit the text,
+// its stroke and its shadow.
+func renderText(face font.Face, lines []string, align string, textColor, strokeColor color.NRGBA, strokeWidth int, shadowColor color.NRGBA, shadowOffset int) *image.NRGBA {
+	metrics := face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	widths, blockWidth := measureLines(face, lines)
+
+	pad := strokeWidth + shadowOffset
+	layer := image.NewNRGBA(image.Rect(0, 0, blockWidth+2*pad, lineHeight*len(lines)+2*pad))
+
+	draw := func(c color.NRGBA, dx, dy int) {
+		drawer := &font.Drawer{Dst: layer, Src: image.NewUniform(c), Face: face}
+		for i, line := range lines {
+			x := pad
+			switch align {
+			case "center":
+				x += (blockWidth - widths[i]) / 2
+			case "right":
+				x += blockWidth - widths[i]
+			}
+			y := pad + i*lineHeight + metrics.Ascent.Ceil()
+
+			drawer.Dot = fixed.P(x+dx, y+dy)
+			drawer.DrawString(line)
+		}
+	}
+
+	if shadowOffset > 0 {
+		draw(shadowColor, shadowOffset, shadowOffset)
+	}
+	for dy := -strokeWidth; dy <= strokeWidth; dy++ {
+		for dx := -strokeWidth; dx <= strokeWidth; dx++ {
+			if (dx != 0 || dy != 0) && dx*dx+dy*dy <= strokeWidth*strokeWidth {
+				draw(strokeColor, dx, dy)
+			}
+		}
+	}
+	draw(textColor, 0, 0)
+
+	return layer
+}
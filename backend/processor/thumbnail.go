@@ -3,6 +3,7 @@ package processor
 import (
 	"database/sql"
 	"fmt"
+	"image"
 	"os"
 	"path/filepath"
 
@@ -10,15 +11,9 @@ import (
 )
 
 func Thumbnail(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
-	var originalPath string
-	err := db.QueryRow("SELECT original_path FROM images WHERE id = $1", imageID).Scan(&originalPath)
+	originalPath, err := sourcePath(db, imageID, params)
 	if err != nil {
-		return "", fmt.Errorf("image not found: %v", err)
-	}
-
-	img, err := imaging.Open(originalPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to open image: %v", err)
+		return "", err
 	}
 
 	// size := int(params["size"].(float64))
@@ -33,16 +28,18 @@ func Thumbnail(db *sql.DB, imageID string, params map[string]interface{}) (strin
 		return "", fmt.Errorf("invalid quality type: %T", v)
 	}
 
+	filter, err := resampleParam(params)
+	if err != nil {
+		return "", err
+	}
+
 	// Create thumbnail (CPU-intensive)
-	thumb := imaging.Thumbnail(img, size, size, imaging.Lanczos)
+	thumbnail := func(img image.Image) (image.Image, error) {
+		return imaging.Thumbnail(img, size, size, filter), nil
+	}
 
-	outputPath := filepath.Join(storageBasePath, fmt.Sprintf("%s_thumb_%d.jpg", imageID, size))
+	outputPath := scratchPath(params, fmt.Sprintf("%s_thumb_%d", imageID, size))
 	os.MkdirAll(filepath.Dir(outputPath), 0755)
 
-	err = imaging.Save(thumb, outputPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to save thumbnail: %v", err)
-	}
-
-	return outputPath, nil
+	return processImage(originalPath, outputPath, params, thumbnail)
 }
@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"database/sql"
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+
+	"github.com/disintegration/imaging"
+)
+
+func Watermark(db *sql.DB, imageID string, params map[string]interface{}) (string, error) {
+	originalPath, err := sourcePath(db, imageID, params)
+	if err != nil {
+		return "", err
+	}
+
+	logoID, err := stringParam(params, "logo_id", "")
+	if err != nil {
+		return "", err
+	}
+	if logoID == "" {
+		return "", fmt.Errorf("logo_id is required")
+	}
+
+	// The logo is an ordinary uploaded image
+	var logoKey string
+	err = db.QueryRow("SELECT original_path FROM images WHERE id = $1", logoID).Scan(&logoKey)
+	if err != nil {
+		return "", fmt.Errorf("logo image not found: %v", err)
+	}
+	logoPath, err := LocalFile(logoKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch logo image: %v", err)
+	}
+
+	img, err := openImage(originalPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to open image: %v", err)
+	}
+
+	logo, err := imaging.Open(logoPath, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", fmt.Errorf("failed to open logo: %v", err)
+	}
+
+	position, err := stringParam(params, "position", "bottom_right")
+	if err != nil {
+		return "", err
+	}
+	anchor, err := parseAnchor(position)
+	if err != nil {
+		return "", err
+	}
+
+	margin, err := intParam(params, "margin", 10)
+	if err != nil {
+		return "", err
+	}
+	scale, err := floatParam(params, "scale", 0.2)
+	if err != nil {
+		return "", err
+	}
+	opacity, err := floatParam(params, "opacity", 0.5)
+	if err != nil {
+		return "", err
+	}
+	tiled, err := boolParam(params, "tiled", false)
+	if err != nil {
+		return "", err
+	}
+
+	if margin < 0 {
+		return "", fmt.Errorf("margin must not be negative")
+	}
+	if scale <= 0 || scale > 1 {
+		return "", fmt.Errorf("scale must be between 0 and 1")
+	}
+	if opacity < 0 || opacity > 1 {
+		return "", fmt.Errorf("opacity must be between 0 and 1")
+	}
+
+	// Scale the logo relative to the target width, keeping its aspect ratio
+	bounds := img.Bounds()
+	logoWidth := int(float64(bounds.Dx()) * scale)
+	if logoWidth < 1 {
+		logoWidth = 1
+	}
+	logo = imaging.Resize(logo, logoWidth, 0, imaging.Lanczos)
+
+	var watermarked *image.NRGBA
+	if tiled {
+		// Lay out all tiles on a transparent layer and blend it once
+		layer := image.NewNRGBA(bounds)
+		size := logo.Bounds().Size()
+		for y := bounds.Min.Y + margin; y < bounds.Max.Y; y += size.Y + margin {
+			for x := bounds.Min.X + margin; x < bounds.Max.X; x += size.X + margin {
+				draw.Draw(layer, image.Rectangle{Min: image.Pt(x, y), Max: image.Pt(x, y).Add(size)}, logo, logo.Bounds().Min, draw.Src)
+			}
+		}
+		watermarked = imaging.Overlay(img, layer, bounds.Min, opacity)
+	} else {
+		pos := anchorPoint(bounds, logo.Bounds().Size(), anchor, margin)
+		watermarked = imaging.Overlay(img, logo, pos, opacity)
+	}
+
+	placement := position
+	if tiled {
+		placement = "tiled"
+	}
+	outputPath := scratchPath(params, fmt.Sprintf("%s_watermark_%s_%s", imageID, logoID, placement))
+	os.MkdirAll(filepath.Dir(outputPath), 0755)
+
+	outputPath, err = saveOutput(watermarked, originalPath, outputPath, params)
+	if err != nil {
+		return "", fmt.Errorf("failed to save watermarked image: %v", err)
+	}
+
+	return outputPath, nil
+}
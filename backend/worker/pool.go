@@ -2,12 +2,18 @@ package worker
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"runtime/debug"
 	"sync"
 	"time"
 
 	"github.com/amandeep2102/image-processor/backend/processor"
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/storage"
 )
 
 type Job struct {
@@ -17,29 +23,26 @@ type Job struct {
 	Parameters map[string]interface{}
 }
 
-type Result struct {
-	JobID            string `json:"job_id"`
-	Success          bool   `json:"success"`
-	ProcessedID      string `json:"processed_id,omitempty"`
-	Message          string `json:"message,omitempty"`
-	ProcessingTimeMs int64  `json:"processing_time_ms"`
-	WorkerID         int    `json:"worker_id"` // Track which worker processed it
-}
+// Result is the outcome of a job, served by GET /job/:job_id.
+type Result = models.JobResult
 
 type Pool struct {
 	workers   int
 	jobQueue  chan Job
 	resultMap sync.Map // Store results by job ID
+	abandoned sync.Map // Synchronous jobs whose caller timed out
 	db        *sql.DB
+	store     *storage.Store
 	wg        sync.WaitGroup
 	stopChan  chan struct{}
 }
 
-func NewPool(workers int, db *sql.DB) *Pool {
+func NewPool(workers int, db *sql.DB, store *storage.Store) *Pool {
 	return &Pool{
 		workers:  workers,
 		jobQueue: make(chan Job, 100), // Buffer for 100 pending jobs
 		db:       db,
+		store:    store,
 		stopChan: make(chan struct{}),
 	}
 }
@@ -95,8 +98,13 @@ func (p *Pool) SubmitAndWait(job Job, timeout time.Duration) (Result, error) {
 			return result, nil
 		}
 
-		// Check timeout
+		// Check timeout. Nobody will collect the result, so it is dropped
+		// here if it just arrived or by the worker once it finishes
 		if time.Now().After(deadline) {
+			p.abandoned.Store(job.JobID, true)
+			if _, ok := p.resultMap.LoadAndDelete(job.JobID); ok {
+				p.abandoned.Delete(job.JobID)
+			}
 			return Result{}, fmt.Errorf("job timeout after %v", timeout)
 		}
 
@@ -123,10 +131,13 @@ func (p *Pool) worker(workerID int) {
 			workerID, job.JobID, job.Operation)
 
 		// Process the job
-		result := p.processJob(job, workerID)
+		result := p.runJob(job, workerID)
 
 		// Store result in map for retrieval
 		p.resultMap.Store(job.JobID, result)
+		if _, ok := p.abandoned.LoadAndDelete(job.JobID); ok {
+			p.resultMap.Delete(job.JobID)
+		}
 
 		log.Printf("Worker %d completed job %s in %dms",
 			workerID, job.JobID, result.ProcessingTimeMs)
@@ -135,22 +146,44 @@ func (p *Pool) worker(workerID int) {
 	log.Printf("Worker %d stopped\n", workerID)
 }
 
+// runJob processes a job and turns a panic in an operation into a failed
+// result, so one bad input cannot take down the backend.
+func (p *Pool) runJob(job Job, workerID int) (result Result) {
+	startTime := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Worker %d: job %s panicked: %v\n%s", workerID, job.JobID, r, debug.Stack())
+			result = Result{
+				JobID:            job.JobID,
+				Success:          false,
+				Message:          "Processing failed unexpectedly",
+				ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+				WorkerID:         workerID,
+			}
+		}
+	}()
+	return p.processJob(job, workerID)
+}
+
 func (p *Pool) processJob(job Job, workerID int) Result {
 	startTime := time.Now()
 
 	var err error
 	var outputPath string
+	var details map[string]interface{}
 
 	switch job.Operation {
-	case "resize":
-		outputPath, err = processor.Resize(p.db, job.ImageID, job.Parameters)
-	case "thumbnail":
-		outputPath, err = processor.Thumbnail(p.db, job.ImageID, job.Parameters)
-	case "filter":
-		outputPath, err = processor.ApplyFilter(p.db, job.ImageID, job.Parameters)
-	case "convert":
-		outputPath, err = processor.Convert(p.db, job.ImageID, job.Parameters)
+	case "responsive":
+		return p.processResponsive(job, workerID, startTime)
+	case "preset":
+		outputPath, details, err = p.runPreset(job)
+	case "transform":
+		outputPath, details, err = p.runTransform(job)
 	default:
+		outputPath, details, err = p.runOperation(job.Operation, job.ImageID, withJobID(job))
+	}
+
+	if err == errUnknownOperation {
 		return Result{
 			JobID:            job.JobID,
 			Success:          false,
@@ -171,30 +204,123 @@ func (p *Pool) processJob(job Job, workerID int) Result {
 	}
 
 	// Save processed image record
-	processedID, err := p.saveProcessedImage(job, outputPath, time.Since(startTime).Milliseconds())
+	processedID, contentHash, err := p.saveProcessedImage(job, outputPath, "", details, time.Since(startTime).Milliseconds())
 	if err != nil {
 		log.Printf("Worker %d: Error saving processed image: %v\n", workerID, err)
+		return Result{
+			JobID:            job.JobID,
+			Success:          false,
+			Message:          fmt.Sprintf("failed to save processed image: %v", err),
+			ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+			WorkerID:         workerID,
+		}
 	}
 
 	return Result{
 		JobID:            job.JobID,
 		Success:          true,
 		ProcessedID:      processedID,
+		ContentHash:      contentHash,
 		Message:          "Processing completed",
 		ProcessingTimeMs: time.Since(startTime).Milliseconds(),
 		WorkerID:         workerID,
+		Details:          details,
+	}
+}
+
+var errUnknownOperation = errors.New("unknown operation")
+
+// withJobID returns a copy of the job parameters carrying the job ID, which
+// processors use to name their scratch output. The job's own parameters are
+// left untouched since they are recorded with the result.
+func withJobID(job Job) map[string]interface{} {
+	params := map[string]interface{}{}
+	for k, v := range job.Parameters {
+		params[k] = v
+	}
+	params["job_id"] = job.JobID
+	return params
+}
+
+// runOperation dispatches an operation that writes a single image.
+func (p *Pool) runOperation(operation, imageID string, params map[string]interface{}) (string, map[string]interface{}, error) {
+	var err error
+	var outputPath string
+	var details map[string]interface{}
+
+	switch operation {
+	case "resize":
+		outputPath, err = processor.Resize(p.db, imageID, params)
+	case "thumbnail":
+		outputPath, err = processor.Thumbnail(p.db, imageID, params)
+	case "crop":
+		outputPath, err = processor.Crop(p.db, imageID, params)
+	case "filter":
+		outputPath, err = processor.ApplyFilter(p.db, imageID, params)
+	case "convert":
+		outputPath, err = processor.Convert(p.db, imageID, params)
+	case "watermark":
+		outputPath, err = processor.Watermark(p.db, imageID, params)
+	case "text":
+		outputPath, err = processor.Text(p.db, imageID, params)
+	case "adjust":
+		outputPath, err = processor.Adjust(p.db, imageID, params)
+	case "lut":
+		outputPath, err = processor.ApplyLUT(p.db, imageID, params)
+	case "auto_enhance":
+		outputPath, details, err = processor.AutoEnhance(p.db, imageID, params)
+	case "redact":
+		outputPath, err = processor.Redact(p.db, imageID, params)
+	default:
+		return "", nil, errUnknownOperation
 	}
+	return outputPath, details, err
 }
 
-func (p *Pool) saveProcessedImage(job Job, outputPath string, processingTime int64) (string, error) {
+// saveProcessedImage records an output. groupID links the outputs of an
+// operation that produces several images and is empty otherwise.
+func (p *Pool) saveProcessedImage(job Job, outputPath, groupID string, details map[string]interface{}, processingTime int64) (string, string, error) {
+	// Keep computed values next to the request parameters so results are reproducible
+	recorded := map[string]interface{}{}
+	for k, v := range job.Parameters {
+		recorded[k] = v
+	}
+	if details != nil {
+		recorded["details"] = details
+	}
+
+	parameters, err := json.Marshal(recorded)
+	if err != nil {
+		return "", "", err
+	}
+
+	var group interface{}
+	if groupID != "" {
+		group = groupID
+	}
+
+	// Identical outputs share one stored file. The alpha flag lets downloads
+	// negotiate a format without decoding the output again
+	contentType := processor.ContentTypeForPath(outputPath)
+	alpha := processor.HasAlpha(outputPath)
+	ref, err := p.store.PutFile(outputPath)
+	if err != nil {
+		os.Remove(outputPath)
+		return "", "", err
+	}
+
 	var id string
-	err := p.db.QueryRow(`
-        INSERT INTO processed_images (original_image_id, operation_type, processed_path, parameters, processing_time_ms)
-        VALUES ($1, $2, $3, $4, $5)
+	err = p.db.QueryRow(`
+        INSERT INTO processed_images (original_image_id, operation_type, processed_path, content_type, has_alpha, group_id, content_hash, parameters, processing_time_ms)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
         RETURNING id
-    `, job.ImageID, job.Operation, outputPath, nil, processingTime).Scan(&id)
+    `, job.ImageID, job.Operation, ref.Key, contentType, alpha, group, ref.Hash, parameters, processingTime).Scan(&id)
+	if err != nil {
+		p.store.Release(ref.Hash)
+		return "", "", err
+	}
 
-	return id, err
+	return id, ref.Hash, nil
 }
 
 // GetQueueSize returns current queue size
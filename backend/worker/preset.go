@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+)
+
+// runPreset runs the steps of a stored preset version.
+func (p *Pool) runPreset(job Job) (string, map[string]interface{}, error) {
+	name, _ := job.Parameters["preset"].(string)
+	version, _ := job.Parameters["version"].(int)
+	steps, ok := job.Parameters["steps"].([]models.PresetStep)
+	if !ok || len(steps) == 0 {
+		return "", nil, fmt.Errorf("preset %s has no steps", name)
+	}
+	return p.runSteps(job, steps, fmt.Sprintf("%s_preset_%s_v%d", job.ImageID, name, version))
+}
+
+// runTransform runs the step of a URL transformation. The output is named
+// after the canonical transformation and its source so differently encoded
+// variants of the same size do not overwrite each other.
+func (p *Pool) runTransform(job Job) (string, map[string]interface{}, error) {
+	canonical, _ := job.Parameters["transform"].(string)
+	source, _ := job.Parameters["source_processed_id"].(string)
+	steps, ok := job.Parameters["steps"].([]models.PresetStep)
+	if !ok || len(steps) == 0 {
+		return "", nil, fmt.Errorf("transformation %s has no steps", canonical)
+	}
+	hash := sha1.Sum([]byte(canonical + "@" + source))
+	return p.runSteps(job, steps, fmt.Sprintf("%s_transform_%x", job.ImageID, hash[:8]))
+}
+
+// runSteps runs steps in order, each reading the output of the one before,
+// and names the final output after the job and outputName plus its
+// extension. Every step writes to a job-specific scratch path, so other jobs
+// on the same image cannot overwrite its output.
+func (p *Pool) runSteps(job Job, steps []models.PresetStep, outputName string) (string, map[string]interface{}, error) {
+	var source string
+	var intermediates []string
+	defer func() {
+		for _, path := range intermediates {
+			os.Remove(path)
+		}
+	}()
+
+	details := map[string]interface{}{}
+	for i, step := range steps {
+		// Set the worker's own keys last so a stored step cannot override
+		// them. The first step may read a source set by the handler
+		params := map[string]interface{}{}
+		for k, v := range step.Parameters {
+			params[k] = v
+		}
+		params["job_id"] = job.JobID
+		if source != "" {
+			params["source_path"] = source
+		}
+
+		outputPath, stepDetails, err := p.runOperation(step.Operation, job.ImageID, params)
+		if err != nil {
+			return "", nil, fmt.Errorf("step %d (%s): %v", i+1, step.Operation, err)
+		}
+		if stepDetails != nil {
+			details[fmt.Sprintf("step_%d", i+1)] = stepDetails
+		}
+
+		// Identical steps would otherwise write to the same scratch file
+		stepPath := filepath.Join(filepath.Dir(outputPath),
+			fmt.Sprintf("%s_step%d%s", job.JobID, i+1, filepath.Ext(outputPath)))
+		if err := os.Rename(outputPath, stepPath); err != nil {
+			return "", nil, fmt.Errorf("step %d (%s): %v", i+1, step.Operation, err)
+		}
+		intermediates = append(intermediates, stepPath)
+		source = stepPath
+	}
+
+	finalPath := filepath.Join(filepath.Dir(source),
+		fmt.Sprintf("%s_%s%s", job.JobID, outputName, filepath.Ext(source)))
+	if err := os.Rename(source, finalPath); err != nil {
+		return "", nil, err
+	}
+
+	if len(details) == 0 {
+		details = nil
+	}
+	return finalPath, details, nil
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/amandeep2102/image-processor/backend/processor"
+	"github.com/amandeep2102/image-processor/shared/signing"
+)
+
+// URLSigningKey signs the download links in responsive results, which the
+// frontend only serves when signed. Set from the configuration; links are
+// left unsigned when it is empty.
+var URLSigningKey []byte
+
+// processResponsive runs the responsive operation, which produces several
+// images. Each is recorded as its own processed image, linked by the job ID
+// as group ID, and the result details carry a srcset per format plus a
+// manifest of all variants. Signed links expire after signing.DefaultTTL;
+// clients re-sign them through the frontend's POST /sign afterwards.
+func (p *Pool) processResponsive(job Job, workerID int, startTime time.Time) Result {
+	variants, err := processor.Responsive(p.db, job.ImageID, withJobID(job))
+	if err != nil {
+		return Result{
+			JobID:            job.JobID,
+			Success:          false,
+			Message:          err.Error(),
+			ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+			WorkerID:         workerID,
+		}
+	}
+
+	groupID := job.JobID
+	elapsed := time.Since(startTime).Milliseconds()
+
+	var manifest []map[string]interface{}
+	var expiresAt time.Time
+	srcsets := map[string][]string{}
+	var saved []string
+	for i, v := range variants {
+		details := map[string]interface{}{
+			"width":  v.Width,
+			"height": v.Height,
+			"format": v.Format,
+		}
+		processedID, contentHash, err := p.saveProcessedImage(job, v.Path, groupID, details, elapsed)
+		if err != nil {
+			// Leave no partial group behind
+			p.discardProcessedImages(saved)
+			for _, rest := range variants[i:] {
+				os.Remove(rest.Path)
+			}
+			return Result{
+				JobID:            job.JobID,
+				Success:          false,
+				Message:          fmt.Sprintf("failed to save processed image: %v", err),
+				ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+				WorkerID:         workerID,
+			}
+		}
+
+		saved = append(saved, processedID)
+
+		url := fmt.Sprintf("/image/%s/processed/%s", job.ImageID, processedID)
+		if len(URLSigningKey) > 0 {
+			url, expiresAt = signing.URL(URLSigningKey, url, signing.DefaultTTL)
+		}
+		manifest = append(manifest, map[string]interface{}{
+			"processed_id": processedID,
+			"content_hash": contentHash,
+			"url":          url,
+			"width":        v.Width,
+			"height":       v.Height,
+			"format":       v.Format,
+			"content_type": processor.ContentTypeForPath(v.Path),
+		})
+		srcsets[v.Format] = append(srcsets[v.Format], fmt.Sprintf("%s %dw", url, v.Width))
+	}
+
+	srcset := map[string]string{}
+	for format, candidates := range srcsets {
+		srcset[format] = strings.Join(candidates, ", ")
+	}
+
+	details := map[string]interface{}{
+		"group_id": groupID,
+		"srcset":   srcset,
+		"manifest": manifest,
+	}
+	if !expiresAt.IsZero() {
+		details["expires_at"] = expiresAt.UTC()
+	}
+
+	return Result{
+		JobID:            job.JobID,
+		Success:          true,
+		Message:          "Processing completed",
+		ProcessingTimeMs: time.Since(startTime).Milliseconds(),
+		WorkerID:         workerID,
+		Details:          details,
+	}
+}
+
+// discardProcessedImages deletes already recorded outputs of a failed job and
+// releases their blobs.
+func (p *Pool) discardProcessedImages(ids []string) {
+	for _, id := range ids {
+		var hash sql.NullString
+		err := p.db.QueryRow("DELETE FROM processed_images WHERE id = $1 RETURNING content_hash", id).Scan(&hash)
+		if err != nil {
+			log.Printf("Failed to discard processed image %s: %v", id, err)
+			continue
+		}
+		if hash.Valid {
+			p.store.Release(hash.String)
+		}
+	}
+}
@@ -4,31 +4,64 @@ import (
 	"database/sql"
 	"log"
 	"os"
+	"regexp"
+	"time"
 
+	"github.com/amandeep2102/image-processor/shared/config"
+	"github.com/amandeep2102/image-processor/shared/storage"
 	_ "github.com/lib/pq"
 )
 
-func fileExists(path string) bool {
-	_, err := os.Stat(path)
-	if os.IsNotExist(err) {
+// blobKeyPattern matches the keys of content-addressed blobs
+var blobKeyPattern = regexp.MustCompile(`^[0-9a-f]{2}/[0-9a-f]{64}(\.[A-Za-z0-9]+)?$`)
+
+// gracePeriod protects blobs that were referenced or written recently. The
+// services add a blob reference before recording the image that holds it, so
+// a younger blob may belong to an upload or job that is still running.
+const gracePeriod = time.Hour
+
+func blobRowExists(key string) bool {
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM blobs WHERE path = $1)", key).Scan(&exists)
+	if err != nil {
+		log.Fatal("Query failed:", err)
+	}
+	return exists
+}
+
+func fileExists(key string) bool {
+	_, err := files.Stat(key)
+	if err == storage.ErrNotExist {
 		return false
 	}
 	return err == nil
 }
 
 var (
-	db *sql.DB
+	db    *sql.DB
+	files storage.Blob
 )
 
 func main() {
-	var err error
-	db, err = sql.Open("postgres",
-		"host=localhost port=5432 user=imageuser password=imagepass dbname=imagedb sslmode=disable")
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+	if len(args) == 0 {
+		log.Fatal("Usage: database [--config file] [--print-config] refresh")
+	}
+
+	db, err = sql.Open("postgres", cfg.Database.DSN)
 	if err != nil {
 		log.Fatal("Failed to open database:", err)
 	}
 	defer db.Close()
 
+	files, err = storage.Open(cfg.Storage.URL, cfg.Storage.AccessKey, cfg.Storage.SecretKey)
+	if err != nil {
+		log.Fatal("Failed to open storage:", err)
+	}
+
 	rows_images, err := db.Query("SELECT id, original_path FROM images")
 	if err != nil {
 		log.Fatal("Query failed:", err)
@@ -41,9 +74,24 @@ func main() {
 	}
 	defer rows_processed.Close()
 
+	rows_luts, err := db.Query("SELECT id, path FROM luts")
+	if err != nil {
+		log.Fatal("Query failed:", err)
+	}
+	defer rows_luts.Close()
+
+	rows_blobs, err := db.Query(`
+        SELECT hash, path FROM blobs
+        WHERE referenced_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+    `, gracePeriod.Seconds())
+	if err != nil {
+		log.Fatal("Query failed:", err)
+	}
+	defer rows_blobs.Close()
+
 	var id, pth string
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "refresh":
 		for rows_images.Next() {
 			err = rows_images.Scan(&id, &pth)
@@ -74,5 +122,86 @@ func main() {
 				}
 			}
 		}
+
+		for rows_luts.Next() {
+			err = rows_luts.Scan(&id, &pth)
+			if err != nil {
+				log.Println("Row scan failed:", err)
+				continue
+			}
+
+			if !fileExists(pth) {
+				_, err := db.Exec("DELETE FROM luts WHERE id = $1", id)
+				if err != nil {
+					log.Fatal("Delete failed:", err)
+				}
+			}
+		}
+
+		for rows_blobs.Next() {
+			err = rows_blobs.Scan(&id, &pth)
+			if err != nil {
+				log.Println("Row scan failed:", err)
+				continue
+			}
+
+			if !fileExists(pth) {
+				_, err := db.Exec("DELETE FROM blobs WHERE hash = $1", id)
+				if err != nil {
+					log.Fatal("Delete failed:", err)
+				}
+			}
+		}
+
+		// Recount references, since rows removed above (and their cascades)
+		// did not release their blobs, then delete blobs nothing refers to.
+		// Recently referenced blobs are skipped: their images may not be
+		// recorded yet, and Put bumps referenced_at, so a reference added
+		// while this runs also keeps the blob out of both statements.
+		_, err = db.Exec(`
+            UPDATE blobs SET ref_count =
+                (SELECT COUNT(*) FROM images WHERE content_hash = blobs.hash) +
+                (SELECT COUNT(*) FROM processed_images WHERE content_hash = blobs.hash)
+            WHERE referenced_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+        `, gracePeriod.Seconds())
+		if err != nil {
+			log.Fatal("Recount failed:", err)
+		}
+
+		rows_orphans, err := db.Query(`
+            DELETE FROM blobs
+            WHERE ref_count = 0 AND referenced_at < CURRENT_TIMESTAMP - make_interval(secs => $1)
+            RETURNING path
+        `, gracePeriod.Seconds())
+		if err != nil {
+			log.Fatal("Delete failed:", err)
+		}
+		defer rows_orphans.Close()
+
+		for rows_orphans.Next() {
+			if err := rows_orphans.Scan(&pth); err != nil {
+				log.Println("Row scan failed:", err)
+				continue
+			}
+			// The same content may have been stored again since
+			if !blobRowExists(pth) {
+				files.Delete(pth)
+			}
+		}
+
+		// Delete stored blobs without a row, left behind by failed writes.
+		// Young files may be blobs whose row is being written
+		stored, err := files.List("")
+		if err != nil {
+			log.Fatal("List failed:", err)
+		}
+		for _, info := range stored {
+			if !blobKeyPattern.MatchString(info.Key) || time.Since(info.ModTime) < gracePeriod {
+				continue
+			}
+			if !blobRowExists(info.Key) {
+				files.Delete(info.Key)
+			}
+		}
 	}
 }
@@ -1,5 +1,15 @@
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
 
+-- Content-addressed files shared by images and processed images
+CREATE TABLE blobs (
+    hash CHAR(64) PRIMARY KEY,
+    path VARCHAR(512) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    referenced_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE images (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
     filename VARCHAR(255) NOT NULL,
@@ -8,10 +18,12 @@ CREATE TABLE images (
     size_bytes BIGINT NOT NULL,
     width INTEGER,
     height INTEGER,
+    has_alpha BOOLEAN,
     uploaded_by VARCHAR(100),
     uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     status VARCHAR(50) DEFAULT 'uploaded',
-    metadata JSONB
+    metadata JSONB,
+    content_hash CHAR(64)
 );
 
 CREATE TABLE processed_images (
@@ -19,11 +31,43 @@ CREATE TABLE processed_images (
     original_image_id UUID REFERENCES images(id) ON DELETE CASCADE,
     operation_type VARCHAR(100) NOT NULL,
     processed_path VARCHAR(512) NOT NULL,
+    content_type VARCHAR(100),
+    has_alpha BOOLEAN,
+    group_id UUID,
+    content_hash CHAR(64),
     parameters JSONB,
     processing_time_ms INTEGER,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE luts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    title VARCHAR(255),
+    filename VARCHAR(255) NOT NULL,
+    path VARCHAR(512) NOT NULL,
+    dimensions INTEGER NOT NULL,
+    size INTEGER NOT NULL,
+    uploaded_by VARCHAR(100),
+    uploaded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Each change to a preset adds a new version; processed images record the
+-- version they were made with
+CREATE TABLE presets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    version INTEGER NOT NULL,
+    description TEXT,
+    steps JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (name, version)
+);
+
 CREATE INDEX idx_images_uploaded_at ON images(uploaded_at);
 CREATE INDEX idx_images_status ON images(status);
-CREATE INDEX idx_processed_original_id ON processed_images(original_image_id);
\ No newline at end of file
+CREATE INDEX idx_processed_original_id ON processed_images(original_image_id);
+CREATE INDEX idx_processed_group_id ON processed_images(group_id);
+CREATE INDEX idx_processed_transform ON processed_images(original_image_id, (parameters->>'transform'));
+CREATE INDEX idx_images_content_hash ON images(content_hash);
+CREATE INDEX idx_processed_content_hash ON processed_images(content_hash);
\ No newline at end of file
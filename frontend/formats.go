@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strings"
+
+	// Decoders for every format accepted on upload
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+)
+
+// uploadContentTypes maps the decoded format name to the content type
+// recorded in images.content_type.
+var uploadContentTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"tiff": "image/tiff",
+	"bmp":  "image/bmp",
+}
+
+// uploadExtensions maps the content type to the extension of the stored
+// blob. It follows the detected format, never the client's file name.
+var uploadExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+	"image/tiff": ".tiff",
+	"image/bmp":  ".bmp",
+}
+
+// detectFormat decodes the image header and returns its real content type and
+// dimensions, regardless of the file name or client-supplied content type.
+func detectFormat(data []byte) (string, image.Config, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", config, fmt.Errorf("unsupported or corrupt image (supported: %s)", supportedFormats())
+	}
+
+	contentType, ok := uploadContentTypes[format]
+	if !ok {
+		return "", config, fmt.Errorf("unsupported image format %s (supported: %s)", format, supportedFormats())
+	}
+	return contentType, config, nil
+}
+
+func supportedFormats() string {
+	formats := make([]string, 0, len(uploadContentTypes))
+	for format := range uploadContentTypes {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return strings.Join(formats, ", ")
+}
+
+// detectAlpha reports whether an uploaded image has transparent pixels. It is
+// recorded at upload so ?format=auto never has to decode the stored file.
+func detectAlpha(data []byte, contentType string) bool {
+	if contentType == "image/jpeg" {
+		return false
+	}
+	return decodeAlpha(bytes.NewReader(data))
+}
+
+// decodeAlpha decodes an image and reports whether it has transparent
+// pixels. Images over maxUploadPixels are not decoded and count as opaque.
+func decodeAlpha(r io.ReadSeeker) bool {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil || config.Width*config.Height > maxUploadPixels {
+		return false
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return false
+	}
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	// "fmt"
@@ -12,68 +13,141 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"time"
 
+	"github.com/amandeep2102/image-processor/shared/config"
+	"github.com/amandeep2102/image-processor/shared/lut"
+	"github.com/amandeep2102/image-processor/shared/metadata"
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/storage"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	_ "github.com/lib/pq"
 )
 
 var (
-	db              *sql.DB
-	backendURL      = "http://localhost:8081"
-	storageBasePath = "/home/polarbeer/Documents/Image-Processor/frontend/storage/uploads"
+	db         *sql.DB
+	files      storage.Blob
+	blobs      *storage.Store
+	backendURL string
+
+	lutNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+)
+
+// maxLUTUploadSize bounds a LUT upload. A 64-point 3D table, the largest one
+// accepted, is about 7 MB of text.
+const maxLUTUploadSize = 16 << 20
+
+// Uploads are read into memory and decoded in full to scrub metadata and
+// detect transparency, so both the file and the image it holds are bounded.
+const (
+	maxUploadSize   = 64 << 20
+	maxUploadPixels = 100_000_000
 )
 
 func main() {
+	cfg, _, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+	if cfg.Signing.Key == "" && !cfg.Signing.Disabled {
+		log.Fatal("Invalid configuration: signing.key is required (set signing.disabled to serve unsigned URLs)")
+	}
+	backendURL = cfg.Frontend.BackendURL
+	urlSigningKey = []byte(cfg.Signing.Key)
+	signingAPIKeys = cfg.Signing.APIKeys
+
 	// Connect to database
-	var err error
-	db, err = sql.Open("postgres",
-		"host=localhost port=5432 user=imageuser password=imagepass dbname=imagedb sslmode=disable")
+	db, err = sql.Open("postgres", cfg.Database.DSN)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 	defer db.Close()
 
-	// Create storage directory
-	os.MkdirAll(storageBasePath, 0755)
+	// Open the storage shared with the backend
+	files, err = storage.Open(cfg.Storage.URL, cfg.Storage.AccessKey, cfg.Storage.SecretKey)
+	if err != nil {
+		log.Fatal("Failed to open storage:", err)
+	}
+	blobs = storage.NewStore(db, files)
 
 	// Setup router
 	r := gin.Default()
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
+		c.JSON(200, models.HealthResponse{Status: "ok"})
 	})
 
 	// Upload/Download endpoints (I/O-bound)
 	r.POST("/upload", handleUpload)
-	r.GET("/image/:id", handleDownload)
-	r.GET("/image/:id/processed/:processed_id", handleDownloadProcessed)
 	r.GET("/images", handleListImages)
 	r.DELETE("/image/:id", handleDelete)
 
+	// Downloads and transformations require signed URLs
+	signed := r.Group("/", requireSignature())
+	signed.GET("/image/:id", handleDownload)
+	signed.GET("/image/:id/processed/:processed_id", handleDownloadProcessed)
+	signed.GET("/i/:id/:transform", handleTransformed)
+	r.POST("/sign", handleSign)
+
+	// LUT assets for the lut operation
+	r.POST("/lut", handleUploadLUT)
+	r.GET("/luts", handleListLUTs)
+	r.DELETE("/lut/:name", handleDeleteLUT)
+
+	// Presets
+	r.POST("/preset", handleCreatePreset)
+	r.GET("/presets", handleListPresets)
+	r.GET("/preset/:name", handleGetPreset)
+	r.PUT("/preset/:name", handleUpdatePreset)
+	r.DELETE("/preset/:name", handleDeletePreset)
+
 	// Processing endpoints (forward to backend - CPU-bound)
 	r.POST("/process/resize", forwardToBackend)
 	r.POST("/process/thumbnail", forwardToBackend)
 	r.POST("/process/filter", forwardToBackend)
 	r.POST("/process/convert", forwardToBackend)
+	r.POST("/process/watermark", forwardToBackend)
+	r.POST("/process/text", forwardToBackend)
+	r.POST("/process/adjust", forwardToBackend)
+	r.POST("/process/lut", forwardToBackend)
+	r.POST("/process/auto_enhance", forwardToBackend)
+	r.POST("/process/redact", forwardToBackend)
+	r.POST("/process/crop", forwardToBackend)
+	r.POST("/process/responsive", forwardToBackend)
+	r.POST("/process/preset/:name", forwardToBackend)
 	r.POST("/process/batch", forwardToBackend)
 
 	// Stats endpoint
 	r.GET("/stats", handleStats)
 
-	log.Println("Frontend server starting on :8080")
-	r.Run(":8080")
+	// JSON Schemas of the request and response models
+	r.GET("/schemas", handleListSchemas)
+	r.GET("/schemas/:name", handleGetSchema)
+
+	log.Println("Frontend server starting on", cfg.Frontend.Addr)
+	r.Run(cfg.Frontend.Addr)
 }
 
 func handleUpload(c *gin.Context) {
 	startTime := time.Now()
 
 	// Parse multipart form (I/O-bound)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
+	if err := c.Request.ParseMultipartForm(maxUploadSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, models.ErrorResponse{Error: fmt.Sprintf("Upload exceeds %d MB", maxUploadSize>>20)})
+			return
+		}
+		c.JSON(400, models.ErrorResponse{Error: "Invalid upload form"})
+		return
+	}
 	file, header, err := c.Request.FormFile("image")
 	if err != nil {
-		c.JSON(400, gin.H{"error": "No file uploaded"})
+		c.JSON(400, models.ErrorResponse{Error: "No file uploaded"})
 		return
 	}
 	defer file.Close()
@@ -82,46 +156,76 @@ func handleUpload(c *gin.Context) {
 	imageID := uuid.New().String()
 	uploadedBy := c.DefaultPostForm("client_id", "anonymous")
 
-	// Save file to disk (I/O-bound)
-	ext := filepath.Ext(header.Filename)
-	filename := imageID + ext
-	filepath := filepath.Join(storageBasePath, filename)
+	policy, err := metadata.ParsePolicy(c.PostForm("metadata_policy"))
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
 
-	out, err := os.Create(filepath)
+	// Read the upload into memory so GPS and device fields never reach disk
+	data, err := io.ReadAll(file)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to save file"})
+		c.JSON(400, models.ErrorResponse{Error: "Failed to read file"})
 		return
 	}
-	defer out.Close()
 
-	size, err := io.Copy(out, file)
+	contentType, config, err := detectFormat(data)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to save file"})
+		c.JSON(415, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if config.Width*config.Height > maxUploadPixels {
+		c.JSON(413, models.ErrorResponse{Error: fmt.Sprintf("Image of %dx%d exceeds the limit of %d pixels", config.Width, config.Height, maxUploadPixels)})
 		return
 	}
 
-	// Get image dimensions
-	// For simplicity, skip this for now
+	data, report, err := metadata.Scrub(data, policy)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: fmt.Sprintf("Failed to process image metadata: %v", err)})
+		return
+	}
+
+	// Save file to storage (I/O-bound); identical uploads share one blob
+	ref, err := blobs.Put(data, uploadExtensions[contentType])
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Failed to save file"})
+		return
+	}
+	size := int64(len(data))
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		blobs.Release(ref.Hash)
+		c.JSON(500, models.ErrorResponse{Error: "Failed to encode metadata report"})
+		return
+	}
 
 	// Save metadata to database (I/O-bound)
 	_, err = db.Exec(`
-        INSERT INTO images (id, filename, original_path, content_type, size_bytes, uploaded_by, status)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
-    `, imageID, header.Filename, filepath, header.Header.Get("Content-Type"), size, uploadedBy, "uploaded")
+        INSERT INTO images (id, filename, original_path, content_type, size_bytes, width, height, has_alpha, uploaded_by, status, metadata, content_hash)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+    `, imageID, header.Filename, ref.Key, contentType, size, config.Width, config.Height, detectAlpha(data, contentType), uploadedBy, "uploaded", reportJSON, ref.Hash)
 
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to save metadata"})
+		blobs.Release(ref.Hash)
+		c.JSON(500, models.ErrorResponse{Error: "Failed to save metadata"})
 		return
 	}
 
 	processingTime := time.Since(startTime).Milliseconds()
 
-	c.JSON(200, gin.H{
-		"id":             imageID,
-		"filename":       header.Filename,
-		"size":           size,
-		"uploaded_by":    uploadedBy,
-		"upload_time_ms": processingTime,
+	c.JSON(200, models.UploadResponse{
+		ID:           imageID,
+		Filename:     header.Filename,
+		Size:         size,
+		ContentType:  contentType,
+		Width:        config.Width,
+		Height:       config.Height,
+		UploadedBy:   uploadedBy,
+		UploadTimeMs: processingTime,
+		Metadata:     report,
+		ContentHash:  ref.Hash,
+		Duplicate:    ref.Duplicate,
 	})
 }
 
@@ -129,132 +233,253 @@ func handleDownload(c *gin.Context) {
 	imageID := c.Param("id")
 
 	// Get image path from database (I/O-bound)
-	var filename, filepath, contentType string
-	err := db.QueryRow("SELECT original_path, content_type, filename FROM images WHERE id = $1", imageID).
-		Scan(&filepath, &contentType, &filename)
+	var filename, filepath, contentType, contentHash string
+	var uploadedAt time.Time
+	var alpha sql.NullBool
+	err := db.QueryRow("SELECT original_path, content_type, filename, uploaded_at, has_alpha, COALESCE(content_hash, '') FROM images WHERE id = $1", imageID).
+		Scan(&filepath, &contentType, &filename, &uploadedAt, &alpha, &contentHash)
 
 	if err != nil {
-		c.JSON(404, gin.H{"error": "Image not found"})
+		c.JSON(404, models.ErrorResponse{Error: "Image not found"})
+		return
+	}
+
+	if serveNegotiated(c, imageID, "", filepath, contentType, filename, alpha) {
 		return
 	}
 
 	// Serve file (I/O-bound)
-	c.Header("Content-Type", contentType)
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	setDisposition(c, filename)
 	// fmt.Println(filename)
-	c.File(filepath)
+	serveFile(c, filepath, contentType, contentHash, uploadedAt, originalCache)
 }
 
 func handleDownloadProcessed(c *gin.Context) {
 	imageID := c.Param("id")
 	processedID := c.Param("processed_id")
 
-	var FilePath string
+	var FilePath, contentType, contentHash string
+	var createdAt time.Time
+	var alpha sql.NullBool
 	err := db.QueryRow(`
-        SELECT processed_path FROM processed_images 
+        SELECT processed_path, COALESCE(content_type, ''), created_at, has_alpha, COALESCE(content_hash, '') FROM processed_images 
         WHERE id = $1 AND original_image_id = $2
-    `, processedID, imageID).Scan(&FilePath)
+    `, processedID, imageID).Scan(&FilePath, &contentType, &createdAt, &alpha, &contentHash)
 
 	if err != nil {
-		c.JSON(404, gin.H{"error": "Processed image not found"})
+		c.JSON(404, models.ErrorResponse{Error: "Processed image not found"})
 		return
 	}
 
 	filename := filepath.Base(FilePath)
 	fmt.Println(filename + "this iss tthe filename ")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(FilePath)))
-	c.File(FilePath)
+	if serveNegotiated(c, imageID, processedID, FilePath, contentType, filename, alpha) {
+		return
+	}
+	setDisposition(c, filename)
+	serveFile(c, FilePath, contentType, contentHash, createdAt, processedCache)
 }
 
 func handleListImages(c *gin.Context) {
 	// Query all images (I/O-bound)
 	rows, err := db.Query(`
-        SELECT id, filename, size_bytes, uploaded_by, uploaded_at, status
+        SELECT id, filename, content_type, size_bytes, COALESCE(width, 0), COALESCE(height, 0),
+               uploaded_by, uploaded_at, status, COALESCE(content_hash, '')
         FROM images
         ORDER BY uploaded_at DESC
     `)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Database query failed"})
+		c.JSON(500, models.ErrorResponse{Error: "Database query failed"})
 		return
 	}
 	defer rows.Close()
 
-	images := make([]map[string]interface{}, 0)
+	images := make([]models.Image, 0)
 	for rows.Next() {
-		var id, filename, uploadedBy, status string
-		var sizeBytes int64
-		var uploadedAt time.Time
-
-		err := rows.Scan(&id, &filename, &sizeBytes, &uploadedBy, &uploadedAt, &status)
+		var img models.Image
+		err := rows.Scan(&img.ID, &img.Filename, &img.ContentType, &img.SizeBytes, &img.Width, &img.Height,
+			&img.UploadedBy, &img.UploadedAt, &img.Status, &img.ContentHash)
 		if err != nil {
 			continue
 		}
-
-		images = append(images, map[string]interface{}{
-			"id":          id,
-			"filename":    filename,
-			"size_bytes":  sizeBytes,
-			"uploaded_by": uploadedBy,
-			"uploaded_at": uploadedAt,
-			"status":      status,
-		})
+		images = append(images, img)
 	}
 
-	c.JSON(200, gin.H{"images": images, "count": len(images)})
+	c.JSON(200, models.ImageListResponse{Images: images, Count: len(images)})
 }
 
 func handleDelete(c *gin.Context) {
 	imageID := c.Param("id")
 
-	// Get file path
-	var filepath string
-	err := db.QueryRow("SELECT original_path FROM images WHERE id = $1", imageID).Scan(&filepath)
+	// Get file path and content hash
+	var filepath, contentHash string
+	err := db.QueryRow("SELECT original_path, COALESCE(content_hash, '') FROM images WHERE id = $1", imageID).
+		Scan(&filepath, &contentHash)
 	if err != nil {
-		c.JSON(404, gin.H{"error": "Image not found"})
+		c.JSON(404, models.ErrorResponse{Error: "Image not found"})
 		return
 	}
 
-	// Get all processed file paths before cascading delete
-	rows, err := db.Query("SELECT processed_path FROM processed_images WHERE original_image_id = $1", imageID)
+	// Get all processed files before cascading delete
+	rows, err := db.Query(`
+        SELECT processed_path, COALESCE(content_hash, '') FROM processed_images WHERE original_image_id = $1
+    `, imageID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to fetch processed image paths"})
+		c.JSON(500, models.ErrorResponse{Error: "Failed to fetch processed image paths"})
 		return
 	}
 	defer rows.Close()
 
-	processedPaths := []string{}
+	type storedFile struct{ path, hash string }
+	stored := []storedFile{{filepath, contentHash}}
 	for rows.Next() {
-		var path string
-		if err := rows.Scan(&path); err == nil {
-			processedPaths = append(processedPaths, path)
+		var f storedFile
+		if err := rows.Scan(&f.path, &f.hash); err == nil {
+			stored = append(stored, f)
 		}
 	}
 
 	// Delete from database (cascades to processed_images)
 	_, err = db.Exec("DELETE FROM images WHERE id = $1", imageID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to delete from database"})
+		c.JSON(500, models.ErrorResponse{Error: "Failed to delete from database"})
+		return
+	}
+
+	// Drop the references; shared files are only removed with the last one.
+	// Files stored before content addressing belong to this image alone.
+	for _, f := range stored {
+		if f.hash != "" {
+			err = blobs.Release(f.hash)
+		} else {
+			err = files.Delete(f.path)
+		}
+		if err != nil {
+			log.Printf("Warning: failed to delete file %s: %v", f.path, err)
+		}
+	}
+
+	c.JSON(200, models.MessageResponse{Message: "Image deleted successfully"})
+}
+
+func handleUploadLUT(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxLUTUploadSize)
+	if err := c.Request.ParseMultipartForm(maxLUTUploadSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(413, models.ErrorResponse{Error: fmt.Sprintf("LUT file exceeds %d MB", maxLUTUploadSize>>20)})
+			return
+		}
+		c.JSON(400, models.ErrorResponse{Error: "Invalid upload form"})
+		return
+	}
+
+	name := c.PostForm("name")
+	if !lutNamePattern.MatchString(name) {
+		c.JSON(400, models.ErrorResponse{Error: "name must be 1-100 letters, digits, '-' or '_'"})
+		return
+	}
+
+	file, header, err := c.Request.FormFile("lut")
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: "No file uploaded"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: "Failed to read file"})
+		return
+	}
+
+	// Reject anything the backend would fail to apply later
+	table, err := lut.Parse(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: fmt.Sprintf("Invalid .cube file: %v", err)})
 		return
 	}
 
-	// Delete all processed files
-	for _, p := range processedPaths {
-		if err := os.Remove(p); err != nil {
-			log.Printf("Warning: failed to delete processed file %s: %v", p, err)
+	var exists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM luts WHERE name = $1)", name).Scan(&exists)
+	if exists {
+		c.JSON(409, models.ErrorResponse{Error: "A LUT with this name already exists"})
+		return
+	}
+
+	key := "luts/" + name + ".cube"
+	if err := files.Put(key, bytes.NewReader(data), int64(len(data)), "text/plain"); err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Failed to save file"})
+		return
+	}
+
+	l := models.LUT{
+		Name:       name,
+		Title:      table.Title,
+		Dimensions: table.Dimensions,
+		Size:       table.Size,
+		UploadedBy: c.DefaultPostForm("client_id", "anonymous"),
+	}
+	err = db.QueryRow(`
+        INSERT INTO luts (name, title, filename, path, dimensions, size, uploaded_by)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id, uploaded_at
+    `, name, table.Title, header.Filename, key, table.Dimensions, table.Size, l.UploadedBy).Scan(&l.ID, &l.UploadedAt)
+	if err != nil {
+		files.Delete(key)
+		c.JSON(500, models.ErrorResponse{Error: "Failed to save metadata"})
+		return
+	}
+
+	c.JSON(200, l)
+}
+
+func handleListLUTs(c *gin.Context) {
+	rows, err := db.Query(`
+        SELECT id, name, COALESCE(title, ''), dimensions, size, uploaded_by, uploaded_at
+        FROM luts
+        ORDER BY name
+    `)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Database query failed"})
+		return
+	}
+	defer rows.Close()
+
+	luts := make([]models.LUT, 0)
+	for rows.Next() {
+		var l models.LUT
+		if err := rows.Scan(&l.ID, &l.Name, &l.Title, &l.Dimensions, &l.Size, &l.UploadedBy, &l.UploadedAt); err != nil {
+			continue
 		}
+		luts = append(luts, l)
 	}
 
-	// Delete file from disk
-	os.Remove(filepath)
+	c.JSON(200, models.LUTListResponse{LUTs: luts, Count: len(luts)})
+}
+
+func handleDeleteLUT(c *gin.Context) {
+	name := c.Param("name")
+
+	var key string
+	err := db.QueryRow("DELETE FROM luts WHERE name = $1 RETURNING path", name).Scan(&key)
+	if err != nil {
+		c.JSON(404, models.ErrorResponse{Error: "LUT not found"})
+		return
+	}
 
-	c.JSON(200, gin.H{"message": "Image deleted successfully"})
+	if err := files.Delete(key); err != nil {
+		log.Printf("Warning: failed to delete LUT file %s: %v", key, err)
+	}
+
+	c.JSON(200, models.MessageResponse{Message: "LUT deleted successfully"})
 }
 
 func forwardToBackend(c *gin.Context) {
 	// Read request body
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read request"})
+		c.JSON(500, models.ErrorResponse{Error: "Failed to read request"})
 		return
 	}
 
@@ -262,7 +487,7 @@ func forwardToBackend(c *gin.Context) {
 	url := backendURL + c.Request.URL.Path
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to create request"})
+		c.JSON(500, models.ErrorResponse{Error: "Failed to create request"})
 		return
 	}
 
@@ -271,7 +496,7 @@ func forwardToBackend(c *gin.Context) {
 	client := &http.Client{Timeout: 60 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Backend request failed"})
+		c.JSON(502, models.ErrorResponse{Error: "Backend request failed"})
 		return
 	}
 	defer resp.Body.Close()
@@ -279,14 +504,16 @@ func forwardToBackend(c *gin.Context) {
 	// Read response
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read response"})
+		c.JSON(502, models.ErrorResponse{Error: "Failed to read response"})
 		return
 	}
 
-	// Forward response
-	var result map[string]interface{}
-	json.Unmarshal(respBody, &result)
-	c.JSON(resp.StatusCode, result)
+	// Forward the backend's models.JobResponse or models.ErrorResponse as is
+	if !json.Valid(respBody) {
+		c.JSON(502, models.ErrorResponse{Error: "Invalid response from backend"})
+		return
+	}
+	c.Data(resp.StatusCode, "application/json; charset=utf-8", respBody)
 }
 
 func handleStats(c *gin.Context) {
@@ -308,31 +535,22 @@ func handleStats(c *gin.Context) {
         GROUP BY operation_type
     `)
 	if err != nil {
-		c.JSON(500, gin.H{"error": fmt.Sprintf("Failed to get stats %v", err.Error())})
+		c.JSON(500, models.ErrorResponse{Error: fmt.Sprintf("Failed to get stats %v", err.Error())})
 		return
 	}
 	defer rows.Close()
 
-	operations := make([]map[string]interface{}, 0)
+	operations := make([]models.OperationStats, 0)
 	for rows.Next() {
-		var opType string
-		var count int
-		var avgTime, minTime, maxTime float64
-
-		rows.Scan(&opType, &count, &avgTime, &minTime, &maxTime)
-		operations = append(operations, map[string]interface{}{
-			"operation":   opType,
-			"count":       count,
-			"avg_time_ms": avgTime,
-			"min_time_ms": minTime,
-			"max_time_ms": maxTime,
-		})
-	}
-
-	c.JSON(200, gin.H{
-		"total_images":     totalImages,
-		"total_processed":  totalProcessed,
-		"total_size_bytes": totalSize,
-		"operations":       operations,
+		var op models.OperationStats
+		rows.Scan(&op.Operation, &op.Count, &op.AvgTimeMs, &op.MinTimeMs, &op.MaxTimeMs)
+		operations = append(operations, op)
+	}
+
+	c.JSON(200, models.StatsResponse{
+		TotalImages:    totalImages,
+		TotalProcessed: totalProcessed,
+		TotalSizeBytes: totalSize,
+		Operations:     operations,
 	})
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Downloads called with ?format=auto are converted to the best format the
+// client accepts:
+//   - GIF is kept as is, so animations survive
+//   - lossless sources become WebP when the client explicitly accepts it
+//   - JPEG and PNG are kept when accepted
+//   - anything else becomes PNG if it has transparency, JPEG otherwise
+// Converted variants are cached like URL transformations.
+
+var negotiatedExtensions = map[string]string{
+	"jpeg": "jpg",
+	"png":  "png",
+	"webp": "webp",
+}
+
+var contentTypeFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+	"image/tiff": "tiff",
+	"image/bmp":  "bmp",
+}
+
+// accepts reports whether the Accept header allows contentType. Wildcards
+// only count when allowWildcard is set, since browsers send image/* without
+// supporting every image format.
+func accepts(accept, contentType string, allowWildcard bool) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && key == "q" {
+				q, _ = strconv.ParseFloat(value, 64)
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		if mediaType == contentType || allowWildcard && (mediaType == "image/*" || mediaType == "*/*") {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateFormat returns the format to convert the file to, or "" to serve
+// it unchanged. Clients that send no Accept header take anything.
+func negotiateFormat(accept, key, contentType string, alpha sql.NullBool) string {
+	source, ok := contentTypeFormats[contentType]
+	if !ok || source == "gif" || strings.TrimSpace(accept) == "" {
+		return ""
+	}
+
+	target := ""
+	switch {
+	case source != "jpeg" && accepts(accept, "image/webp", false):
+		target = "webp"
+	case (source == "jpeg" || source == "png") && accepts(accept, contentType, true):
+		target = source
+	case hasAlpha(alpha, key):
+		target = "png"
+	default:
+		target = "jpeg"
+	}
+
+	if target == source {
+		return ""
+	}
+	return target
+}
+
+// hasAlpha returns the transparency flag recorded when the image was stored.
+// Rows written before the flag existed are decoded instead.
+func hasAlpha(recorded sql.NullBool, key string) bool {
+	if recorded.Valid {
+		return recorded.Bool
+	}
+
+	f, err := files.Get(key)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return decodeAlpha(f)
+}
+
+// serveNegotiated serves a converted variant when ?format=auto asks for one
+// and a better format applies. It reports whether it handled the request.
+func serveNegotiated(c *gin.Context, imageID, sourceID, key, contentType, filename string, alpha sql.NullBool) bool {
+	if c.Query("format") != "auto" {
+		return false
+	}
+	c.Header("Vary", "Accept")
+
+	format := negotiateFormat(c.GetHeader("Accept"), key, contentType, alpha)
+	if format == "" {
+		return false
+	}
+
+	name := strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + negotiatedExtensions[format]
+	setDisposition(c, name)
+	serveTransformed(c, imageID, sourceID, "."+format)
+	return true
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/preset"
+	"github.com/gin-gonic/gin"
+)
+
+// Presets are never edited in place: updating one inserts the next version,
+// so outputs made with an older version can still be traced to its steps.
+
+func handleCreatePreset(c *gin.Context) {
+	var req models.CreatePresetRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := preset.ValidateName(req.Name); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if err := preset.Validate(req.Steps); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var exists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM presets WHERE name = $1)", req.Name).Scan(&exists)
+	if exists {
+		c.JSON(409, models.ErrorResponse{Error: "A preset with this name already exists, update it to add a version"})
+		return
+	}
+
+	p, err := insertPresetVersion(req.Name, 1, req.Description, req.Steps)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Failed to save preset"})
+		return
+	}
+
+	c.JSON(201, p)
+}
+
+func handleUpdatePreset(c *gin.Context) {
+	name := c.Param("name")
+
+	var req models.UpdatePresetRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	if err := preset.Validate(req.Steps); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	var latest int
+	db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM presets WHERE name = $1", name).Scan(&latest)
+	if latest == 0 {
+		c.JSON(404, models.ErrorResponse{Error: "Preset not found"})
+		return
+	}
+
+	p, err := insertPresetVersion(name, latest+1, req.Description, req.Steps)
+	if err != nil {
+		// Most likely a concurrent update took this version number
+		c.JSON(409, models.ErrorResponse{Error: "Failed to save preset version, retry the update"})
+		return
+	}
+
+	c.JSON(200, p)
+}
+
+func insertPresetVersion(name string, version int, description string, steps []models.PresetStep) (models.Preset, error) {
+	p := models.Preset{Name: name, Version: version, Description: description, Steps: steps}
+
+	encoded, err := json.Marshal(steps)
+	if err != nil {
+		return p, err
+	}
+
+	err = db.QueryRow(`
+        INSERT INTO presets (name, version, description, steps)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at
+    `, name, version, description, encoded).Scan(&p.ID, &p.CreatedAt)
+	return p, err
+}
+
+// handleGetPreset returns the latest version, or the one given by ?version=,
+// along with the list of all versions.
+func handleGetPreset(c *gin.Context) {
+	name := c.Param("name")
+
+	version := 0
+	if v := c.Query("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version < 1 {
+			c.JSON(400, models.ErrorResponse{Error: "Invalid version"})
+			return
+		}
+	}
+
+	p, err := scanPreset(db.QueryRow(`
+        SELECT id, name, version, COALESCE(description, ''), steps, created_at
+        FROM presets
+        WHERE name = $1 AND ($2 = 0 OR version = $2)
+        ORDER BY version DESC
+        LIMIT 1
+    `, name, version))
+	if err == sql.ErrNoRows {
+		c.JSON(404, models.ErrorResponse{Error: "Preset not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Database query failed"})
+		return
+	}
+
+	rows, err := db.Query("SELECT version, created_at FROM presets WHERE name = $1 ORDER BY version", name)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Database query failed"})
+		return
+	}
+	defer rows.Close()
+
+	versions := make([]models.PresetVersion, 0)
+	for rows.Next() {
+		var v models.PresetVersion
+		if err := rows.Scan(&v.Version, &v.CreatedAt); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	c.JSON(200, models.PresetResponse{Preset: p, Versions: versions})
+}
+
+// handleListPresets returns the latest version of every preset.
+func handleListPresets(c *gin.Context) {
+	rows, err := db.Query(`
+        SELECT DISTINCT ON (name) id, name, version, COALESCE(description, ''), steps, created_at
+        FROM presets
+        ORDER BY name, version DESC
+    `)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Database query failed"})
+		return
+	}
+	defer rows.Close()
+
+	presets := make([]models.Preset, 0)
+	for rows.Next() {
+		p, err := scanPreset(rows)
+		if err != nil {
+			continue
+		}
+		presets = append(presets, p)
+	}
+
+	c.JSON(200, models.PresetListResponse{Presets: presets, Count: len(presets)})
+}
+
+// handleDeletePreset removes every version of a preset. Processed images
+// keep the steps they were made with in their parameters.
+func handleDeletePreset(c *gin.Context) {
+	name := c.Param("name")
+
+	result, err := db.Exec("DELETE FROM presets WHERE name = $1", name)
+	if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Failed to delete preset"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(404, models.ErrorResponse{Error: "Preset not found"})
+		return
+	}
+
+	c.JSON(200, models.MessageResponse{Message: fmt.Sprintf("Preset %s deleted successfully", name)})
+}
+
+func scanPreset(row interface{ Scan(...interface{}) error }) (models.Preset, error) {
+	var p models.Preset
+	var steps []byte
+	if err := row.Scan(&p.ID, &p.Name, &p.Version, &p.Description, &steps, &p.CreatedAt); err != nil {
+		return p, err
+	}
+	err := json.Unmarshal(steps, &p.Steps)
+	return p, err
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// Clients can validate requests before sending them, and responses after,
+// against the schemas of the shared models. The same schemas are written to
+// files by shared/cmd/schemagen.
+
+func handleListSchemas(c *gin.Context) {
+	names := make([]string, 0, len(models.Types))
+	for name := range models.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c.JSON(200, models.SchemaListResponse{Schemas: names})
+}
+
+func handleGetSchema(c *gin.Context) {
+	name := c.Param("name")
+
+	v, ok := models.Types[name]
+	if !ok {
+		c.JSON(404, models.ErrorResponse{Error: "Schema not found"})
+		return
+	}
+
+	c.Header("Content-Type", "application/schema+json")
+	c.JSON(200, schema.For(name, v))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Cache policies. An ID always refers to the same bytes, but originals can be
+// deleted, so only derived images are marked immutable.
+type cachePolicy struct {
+	maxAge    time.Duration
+	immutable bool
+}
+
+var (
+	originalCache  = cachePolicy{maxAge: 24 * time.Hour}
+	processedCache = cachePolicy{maxAge: 365 * 24 * time.Hour, immutable: true}
+)
+
+// cacheControl returns the Cache-Control header for the request. A signed
+// URL must stop working when it expires, so shared caches may only keep the
+// response for the rest of the signature's lifetime.
+func (p cachePolicy) cacheControl(c *gin.Context) string {
+	if len(urlSigningKey) != 0 {
+		expires, _ := strconv.ParseInt(c.Query("expires"), 10, 64)
+		if remaining := time.Until(time.Unix(expires, 0)); remaining < p.maxAge {
+			return fmt.Sprintf("public, max-age=%d", max(int64(remaining.Seconds()), 0))
+		}
+	}
+
+	header := fmt.Sprintf("public, max-age=%d", int64(p.maxAge.Seconds()))
+	if p.immutable {
+		header += ", immutable"
+	}
+	return header
+}
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// etags remembers content hashes by key, so unchanged blobs are hashed once.
+var etags sync.Map
+
+// fileETag returns a strong ETag for the blob from the SHA-256 of its
+// content, leaving f positioned at the start. It is only needed for rows
+// recorded before content hashes were stored.
+func fileETag(key string, info storage.Info, f io.ReadSeeker) (string, error) {
+	if v, ok := etags.Load(key); ok {
+		entry := v.(etagEntry)
+		if entry.modTime.Equal(info.ModTime) && entry.size == info.Size {
+			return entry.etag, nil
+		}
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+	etags.Store(key, etagEntry{modTime: info.ModTime, size: info.Size, etag: etag})
+	return etag, nil
+}
+
+// setDisposition offers the file as a download, or for display in the
+// browser with ?inline=1.
+func setDisposition(c *gin.Context, filename string) {
+	disposition := "attachment"
+	if c.Query("inline") == "1" {
+		disposition = "inline"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filename))
+}
+
+// serveFile sends the stored file with ETag, Last-Modified and Cache-Control
+// headers. The ETag is the recorded content hash when there is one.
+// Conditional requests (If-None-Match, If-Modified-Since) and byte ranges are
+// handled by http.ServeContent.
+func serveFile(c *gin.Context, key, contentType, contentHash string, modTime time.Time, policy cachePolicy) {
+	info, err := files.Stat(key)
+	if err != nil {
+		c.JSON(404, models.ErrorResponse{Error: "File not found"})
+		return
+	}
+
+	f, err := files.Get(key)
+	if err != nil {
+		c.JSON(404, models.ErrorResponse{Error: "File not found"})
+		return
+	}
+	defer f.Close()
+
+	etag := `"` + contentHash + `"`
+	if contentHash == "" {
+		if etag, err = fileETag(key, info, f); err != nil {
+			c.JSON(500, models.ErrorResponse{Error: "Failed to read file"})
+			return
+		}
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", policy.cacheControl(c))
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	http.ServeContent(c.Writer, c.Request, "", modTime, f)
+}
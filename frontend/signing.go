@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/signing"
+	"github.com/gin-gonic/gin"
+)
+
+// Download and transformation URLs are signed (see shared/signing). The
+// frontend requires the signing key unless signing is explicitly disabled in
+// the configuration, in which case links are not checked. Clients holding one
+// of the signing API keys obtain signed URLs from POST /sign; the backend
+// signs the links in responsive job results with the same key.
+//
+// Signing only protects the image bytes. Listing images (GET /images),
+// uploads, deletes and processing requests are not covered and belong behind
+// the deployment's own authentication.
+
+const maxSignedURLTTL = 7 * 24 * time.Hour
+
+// Set from the configuration.
+var (
+	urlSigningKey  []byte
+	signingAPIKeys []string
+)
+
+// requireSignature rejects requests whose signature is missing, does not
+// match the path or has expired.
+func requireSignature() gin.HandlerFunc {
+	if len(urlSigningKey) == 0 {
+		log.Println("Warning: signing is disabled, download and transform URLs are not signed")
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+		signature := c.Query("signature")
+		if err != nil || signature == "" {
+			c.AbortWithStatusJSON(403, models.ErrorResponse{Error: "Signed URL required"})
+			return
+		}
+
+		expected := signing.Signature(urlSigningKey, c.Request.URL.EscapedPath(), expires)
+		if !hmac.Equal([]byte(signature), []byte(expected)) {
+			c.AbortWithStatusJSON(403, models.ErrorResponse{Error: "Invalid signature"})
+			return
+		}
+		if time.Now().Unix() > expires {
+			c.AbortWithStatusJSON(403, models.ErrorResponse{Error: "Signed URL has expired"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func validAPIKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	valid := false
+	for _, k := range signingAPIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// handleSign returns a signed URL for a download or transformation path.
+func handleSign(c *gin.Context) {
+	if len(urlSigningKey) == 0 {
+		c.JSON(503, models.ErrorResponse{Error: "URL signing is not configured"})
+		return
+	}
+	if !validAPIKey(c.GetHeader("X-API-Key")) {
+		c.JSON(401, models.ErrorResponse{Error: "Invalid API key"})
+		return
+	}
+
+	var req models.SignRequest
+
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	u, err := url.Parse(req.Path)
+	if err != nil || u.IsAbs() || u.RawQuery != "" ||
+		!(strings.HasPrefix(u.Path, "/image/") || strings.HasPrefix(u.Path, "/i/")) {
+		c.JSON(400, models.ErrorResponse{Error: "path must be an /image/ or /i/ path without query"})
+		return
+	}
+
+	ttl := signing.DefaultTTL
+	if req.ExpiresIn != 0 {
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if ttl <= 0 || ttl > maxSignedURLTTL {
+		c.JSON(400, models.ErrorResponse{Error: "expires_in must be between 1 second and 7 days"})
+		return
+	}
+
+	signed, expiresAt := signing.URL(urlSigningKey, u.EscapedPath(), ttl)
+	c.JSON(200, models.SignResponse{
+		URL:       signed,
+		ExpiresAt: expiresAt.UTC(),
+	})
+}
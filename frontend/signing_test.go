@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/signing"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// withSigning sets the signing configuration for the duration of a test.
+func withSigning(t *testing.T, key string, apiKeys ...string) {
+	t.Helper()
+	oldKey, oldAPIKeys := urlSigningKey, signingAPIKeys
+	t.Cleanup(func() { urlSigningKey, signingAPIKeys = oldKey, oldAPIKeys })
+	urlSigningKey, signingAPIKeys = []byte(key), apiKeys
+}
+
+func signedRouter() *gin.Engine {
+	r := gin.New()
+	signed := r.Group("/", requireSignature())
+	signed.GET("/image/:id", func(c *gin.Context) { c.Status(200) })
+	signed.GET("/i/:id/:transform", func(c *gin.Context) { c.Status(200) })
+	r.POST("/sign", handleSign)
+	return r
+}
+
+func get(r http.Handler, target string) int {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+	return w.Code
+}
+
+func TestRequireSignature(t *testing.T) {
+	withSigning(t, "secret")
+	r := signedRouter()
+
+	path := "/image/abc"
+	valid, _ := signing.URL([]byte("secret"), path, time.Hour)
+	otherKey, _ := signing.URL([]byte("other"), path, time.Hour)
+	expires := time.Now().Add(-time.Minute).Unix()
+	expired := path + "?expires=" + strconv.FormatInt(expires, 10) +
+		"&signature=" + signing.Signature([]byte("secret"), path, expires)
+	_, query, _ := strings.Cut(valid, "?")
+	transform, _ := signing.URL([]byte("secret"), "/i/abc/w_400%2Ch_300.png", time.Hour)
+
+	tests := []struct {
+		name   string
+		target string
+		want   int
+	}{
+		{"valid", valid, 200},
+		{"escaped path", transform, 200},
+		{"unsigned", path, 403},
+		{"signature only", path + "?signature=x", 403},
+		{"tampered path", "/image/xyz?" + query, 403},
+		{"tampered expiry", strings.Replace(valid, "expires=", "expires=9", 1), 403},
+		{"wrong key", otherKey, 403},
+		{"expired", expired, 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := get(r, tt.target); got != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireSignatureDisabled(t *testing.T) {
+	withSigning(t, "")
+	if got := get(signedRouter(), "/image/abc"); got != 200 {
+		t.Errorf("unsigned request with signing disabled = %d, want 200", got)
+	}
+}
+
+func TestSign(t *testing.T) {
+	withSigning(t, "secret", "client-key")
+	r := signedRouter()
+
+	sign := func(apiKey, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/sign", strings.NewReader(body))
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name   string
+		apiKey string
+		body   string
+		want   int
+	}{
+		{"valid", "client-key", `{"path": "/image/abc"}`, 200},
+		{"missing API key", "", `{"path": "/image/abc"}`, 401},
+		{"wrong API key", "other", `{"path": "/image/abc"}`, 401},
+		{"other route", "client-key", `{"path": "/images"}`, 400},
+		{"absolute URL", "client-key", `{"path": "http://example.com/image/abc"}`, 400},
+		{"with query", "client-key", `{"path": "/image/abc?inline=1"}`, 400},
+		{"negative ttl", "client-key", `{"path": "/image/abc", "expires_in": -1}`, 400},
+		{"ttl too long", "client-key", `{"path": "/image/abc", "expires_in": 604801}`, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := sign(tt.apiKey, tt.body); w.Code != tt.want {
+				t.Errorf("POST /sign = %d %s, want %d", w.Code, w.Body, tt.want)
+			}
+		})
+	}
+
+	// The signed URL passes requireSignature
+	w := sign("client-key", `{"path": "/image/abc", "expires_in": 60}`)
+	var resp models.SignResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(r, resp.URL); got != 200 {
+		t.Errorf("GET %s = %d, want 200", resp.URL, got)
+	}
+	if until := time.Until(resp.ExpiresAt); until <= 0 || until > time.Minute {
+		t.Errorf("expires at %v, want within a minute", resp.ExpiresAt)
+	}
+}
+
+func TestSignRejectsEmptyAPIKey(t *testing.T) {
+	// A configured empty key must not match a request without the header
+	withSigning(t, "secret", "")
+	w := httptest.NewRecorder()
+	r := signedRouter()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/sign", strings.NewReader(`{"path": "/image/abc"}`)))
+	if w.Code != 401 {
+		t.Errorf("POST /sign without an API key = %d, want 401", w.Code)
+	}
+}
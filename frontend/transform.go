@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/transform"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
+)
+
+// handleTransformed serves /i/:id/:transform, e.g. /i/<id>/w_400,h_300.png.
+// Results are stored as processed images keyed by the canonical
+// transformation, so each variant is generated once and then served from
+// storage with the immutable cache policy of processed images.
+func handleTransformed(c *gin.Context) {
+	imageID := c.Param("id")
+
+	options, err := transform.Parse(c.Param("transform"))
+	if err != nil {
+		c.JSON(400, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	canonical := options.String()
+
+	var exists bool
+	db.QueryRow("SELECT EXISTS(SELECT 1 FROM images WHERE id = $1)", imageID).Scan(&exists)
+	if !exists {
+		c.JSON(404, models.ErrorResponse{Error: "Image not found"})
+		return
+	}
+
+	serveTransformed(c, imageID, "", canonical)
+}
+
+// serveTransformed serves a transformation of the original image or, when
+// sourceID is set, of one of its processed images, generating it on the
+// backend if it is not cached yet.
+func serveTransformed(c *gin.Context, imageID, sourceID, canonical string) {
+	var processedID, path, contentType, contentHash string
+	var createdAt time.Time
+	err := db.QueryRow(`
+        SELECT id, processed_path, COALESCE(content_type, ''), created_at, COALESCE(content_hash, '') FROM processed_images
+        WHERE original_image_id = $1 AND operation_type = 'transform' AND parameters->>'transform' = $2
+          AND COALESCE(parameters->>'source_processed_id', '') = $3
+        ORDER BY created_at DESC
+        LIMIT 1
+    `, imageID, canonical, sourceID).Scan(&processedID, &path, &contentType, &createdAt, &contentHash)
+
+	if err == nil {
+		if _, statErr := files.Stat(path); statErr != nil {
+			// The cached file is gone; drop the stale record and regenerate.
+			// Only the request that deletes the row releases its blob
+			res, delErr := db.Exec("DELETE FROM processed_images WHERE id = $1", processedID)
+			if delErr == nil && contentHash != "" {
+				if n, _ := res.RowsAffected(); n == 1 {
+					blobs.Release(contentHash)
+				}
+			}
+			err = sql.ErrNoRows
+		}
+	}
+
+	if err == sql.ErrNoRows {
+		// Concurrent requests for the same uncached transformation share
+		// one backend job
+		key := imageID + "\x00" + sourceID + "\x00" + canonical
+		v, err, _ := transformCalls.Do(key, func() (interface{}, error) {
+			var result transformResult
+			var err error
+			result.status, err = requestTransform(imageID, sourceID, canonical, &result.processedID)
+			return result, err
+		})
+		result := v.(transformResult)
+		if err != nil {
+			c.JSON(result.status, models.ErrorResponse{Error: err.Error()})
+			return
+		}
+		processedID = result.processedID
+		err = db.QueryRow(`
+            SELECT processed_path, COALESCE(content_type, ''), created_at, COALESCE(content_hash, '') FROM processed_images WHERE id = $1
+        `, processedID).Scan(&path, &contentType, &createdAt, &contentHash)
+		if err != nil {
+			c.JSON(500, models.ErrorResponse{Error: "Transformed image was not recorded"})
+			return
+		}
+	} else if err != nil {
+		c.JSON(500, models.ErrorResponse{Error: "Database query failed"})
+		return
+	}
+
+	serveFile(c, path, contentType, contentHash, createdAt, processedCache)
+}
+
+// transformCalls deduplicates in-flight transformations by image, source
+// and canonical transformation.
+var transformCalls singleflight.Group
+
+type transformResult struct {
+	status      int
+	processedID string
+}
+
+// requestTransform runs the transformation synchronously on the backend and
+// stores the ID of the resulting processed image. On failure it returns the
+// status to respond with.
+func requestTransform(imageID, sourceID, canonical string, processedID *string) (int, error) {
+	body, _ := json.Marshal(models.TransformRequest{
+		ImageID:           imageID,
+		SourceProcessedID: sourceID,
+		Transform:         canonical,
+	})
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Post(backendURL+"/transform", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 502, fmt.Errorf("Backend request failed")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		models.TransformResponse
+		models.ErrorResponse
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 502, fmt.Errorf("Invalid backend response")
+	}
+	if resp.StatusCode != 200 {
+		return resp.StatusCode, fmt.Errorf("%s", result.Error)
+	}
+	if result.ProcessedID == "" {
+		return 500, fmt.Errorf("Transformed image was not recorded")
+	}
+
+	*processedID = result.ProcessedID
+	return 200, nil
+}
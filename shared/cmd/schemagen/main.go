@@ -0,0 +1,37 @@
+// Command schemagen writes the JSON Schema of every shared model to a
+// directory, one <Name>.json file each:
+//
+//	go run ./cmd/schemagen schemas
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+	"github.com/amandeep2102/image-processor/shared/schema"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("Usage: schemagen <output directory>")
+	}
+	dir := os.Args[1]
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Fatal("Failed to create output directory:", err)
+	}
+
+	for name, v := range models.Types {
+		data, err := json.MarshalIndent(schema.For(name, v), "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode schema %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0644); err != nil {
+			log.Fatalf("Failed to write schema %s: %v", name, err)
+		}
+	}
+	log.Printf("Wrote %d schemas to %s", len(models.Types), dir)
+}
@@ -0,0 +1,237 @@
+// Package config loads the settings shared by the frontend, backend and
+// database tool. Values come from the defaults below, then an optional YAML
+// file, then environment variables, each overriding the one before.
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+type Config struct {
+	Database DatabaseConfig `yaml:"database"`
+	Frontend FrontendConfig `yaml:"frontend"`
+	Backend  BackendConfig  `yaml:"backend"`
+	Storage  StorageConfig  `yaml:"storage"`
+	Signing  SigningConfig  `yaml:"signing"`
+}
+
+type DatabaseConfig struct {
+	DSN string `yaml:"dsn"`
+}
+
+type FrontendConfig struct {
+	Addr       string `yaml:"addr"`
+	BackendURL string `yaml:"backend_url"`
+}
+
+type BackendConfig struct {
+	Addr       string `yaml:"addr"`
+	Workers    int    `yaml:"workers"`
+	ScratchDir string `yaml:"scratch_dir"` // processors write output here before it is stored
+}
+
+type StorageConfig struct {
+	URL       string `yaml:"url"` // local directory, file:// or s3:// URL
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+}
+
+type SigningConfig struct {
+	Key      string   `yaml:"key"`      // required unless signing is disabled
+	APIKeys  []string `yaml:"api_keys"` // clients allowed to request signed URLs
+	Disabled bool     `yaml:"disabled"` // serve downloads and transformations unsigned
+}
+
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			DSN: "host=localhost port=5432 user=imageuser password=imagepass dbname=imagedb sslmode=disable",
+		},
+		Frontend: FrontendConfig{
+			Addr:       ":8080",
+			BackendURL: "http://localhost:8081",
+		},
+		Backend: BackendConfig{
+			Addr:       ":8081",
+			Workers:    10,
+			ScratchDir: "./scratch",
+		},
+		Storage: StorageConfig{
+			URL: "./storage",
+		},
+	}
+}
+
+// envVars maps environment variables onto the configuration.
+var envVars = []struct {
+	name  string
+	apply func(c *Config, value string) error
+}{
+	{"DATABASE_URL", func(c *Config, v string) error { c.Database.DSN = v; return nil }},
+	{"FRONTEND_ADDR", func(c *Config, v string) error { c.Frontend.Addr = v; return nil }},
+	{"BACKEND_URL", func(c *Config, v string) error { c.Frontend.BackendURL = v; return nil }},
+	{"BACKEND_ADDR", func(c *Config, v string) error { c.Backend.Addr = v; return nil }},
+	{"WORKERS", func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		c.Backend.Workers = n
+		return nil
+	}},
+	{"SCRATCH_DIR", func(c *Config, v string) error { c.Backend.ScratchDir = v; return nil }},
+	{"STORAGE_URL", func(c *Config, v string) error { c.Storage.URL = v; return nil }},
+	{"AWS_ACCESS_KEY_ID", func(c *Config, v string) error { c.Storage.AccessKey = v; return nil }},
+	{"AWS_SECRET_ACCESS_KEY", func(c *Config, v string) error { c.Storage.SecretKey = v; return nil }},
+	{"URL_SIGNING_KEY", func(c *Config, v string) error { c.Signing.Key = v; return nil }},
+	{"SIGNING_API_KEYS", func(c *Config, v string) error { c.Signing.APIKeys = splitList(v); return nil }},
+	{"SIGNING_DISABLED", func(c *Config, v string) error {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		c.Signing.Disabled = disabled
+		return nil
+	}},
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Load parses the command line flags --config (or IMAGE_PROCESSOR_CONFIG)
+// and --print-config, and returns the validated configuration along with
+// the remaining arguments. With --print-config the configuration is printed,
+// secrets redacted, and the program exits.
+func Load(args []string) (*Config, []string, error) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	path := fs.String("config", os.Getenv("IMAGE_PROCESSOR_CONFIG"), "path to a YAML config file")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+	fs.Parse(args)
+
+	cfg := Default()
+	if *path != "" {
+		if err := cfg.loadFile(*path); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, env := range envVars {
+		if value, ok := os.LookupEnv(env.name); ok {
+			if err := env.apply(cfg, value); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", env.name, err)
+			}
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if *printConfig {
+		out, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			return nil, nil, err
+		}
+		os.Stdout.Write(out)
+		os.Exit(0)
+	}
+	return cfg, fs.Args(), nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	// Unknown keys are rejected so typos do not silently fall back to defaults
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting.
+func (c *Config) Validate() error {
+	if c.Database.DSN == "" {
+		return fmt.Errorf("database.dsn is required")
+	}
+	if c.Frontend.Addr == "" {
+		return fmt.Errorf("frontend.addr is required")
+	}
+	if u, err := url.Parse(c.Frontend.BackendURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("frontend.backend_url must be an http(s) URL")
+	}
+	if c.Backend.Addr == "" {
+		return fmt.Errorf("backend.addr is required")
+	}
+	if c.Backend.Workers < 1 || c.Backend.Workers > 256 {
+		return fmt.Errorf("backend.workers must be between 1 and 256")
+	}
+	if c.Backend.ScratchDir == "" {
+		return fmt.Errorf("backend.scratch_dir is required")
+	}
+	if c.Storage.URL == "" {
+		return fmt.Errorf("storage.url is required")
+	}
+	if strings.HasPrefix(c.Storage.URL, "s3://") && (c.Storage.AccessKey == "" || c.Storage.SecretKey == "") {
+		return fmt.Errorf("storage.access_key and storage.secret_key are required for s3 storage")
+	}
+	if len(c.Signing.APIKeys) > 0 && c.Signing.Key == "" {
+		return fmt.Errorf("signing.api_keys requires signing.key")
+	}
+	for _, key := range c.Signing.APIKeys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("signing.api_keys must not contain empty keys")
+		}
+	}
+	if c.Signing.Disabled && c.Signing.Key != "" {
+		return fmt.Errorf("signing.disabled cannot be combined with signing.key")
+	}
+	return nil
+}
+
+var dsnPassword = regexp.MustCompile(`(password=)\S+`)
+
+// Redacted returns a copy with passwords and keys masked, for printing.
+func (c *Config) Redacted() *Config {
+	r := *c
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "<redacted>"
+	}
+
+	if u, err := url.Parse(r.Database.DSN); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+			r.Database.DSN = u.String()
+		}
+	}
+	r.Database.DSN = dsnPassword.ReplaceAllString(r.Database.DSN, "${1}<redacted>")
+	r.Storage.SecretKey = mask(r.Storage.SecretKey)
+	r.Signing.Key = mask(r.Signing.Key)
+	r.Signing.APIKeys = nil
+	for range c.Signing.APIKeys {
+		r.Signing.APIKeys = append(r.Signing.APIKeys, "<redacted>")
+	}
+	return &r
+}
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestValidateSigning(t *testing.T) {
+	tests := []struct {
+		name    string
+		signing SigningConfig
+		wantErr bool
+	}{
+		{name: "key", signing: SigningConfig{Key: "secret"}},
+		{name: "key and API keys", signing: SigningConfig{Key: "secret", APIKeys: []string{"client"}}},
+		{name: "disabled", signing: SigningConfig{Disabled: true}},
+		{name: "API keys without key", signing: SigningConfig{APIKeys: []string{"client"}}, wantErr: true},
+		{name: "empty API key", signing: SigningConfig{Key: "secret", APIKeys: []string{""}}, wantErr: true},
+		{name: "blank API key", signing: SigningConfig{Key: "secret", APIKeys: []string{"client", "  "}}, wantErr: true},
+		{name: "disabled with key", signing: SigningConfig{Key: "secret", Disabled: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			c.Signing = tt.signing
+			if err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package lut
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A 3D table holds size³ entries, so it is capped at 64, the largest size
+// common grading tools export.
+const (
+	max1DSize = 65536
+	max3DSize = 64
+)
+
+// LUT is a parsed Adobe .cube lookup table. Table entries are stored in file
+// order: for 3D tables the red index changes fastest.
+type LUT struct {
+	Title      string
+	Dimensions int
+	Size       int
+	DomainMin  [3]float64
+	DomainMax  [3]float64
+	Table      [][3]float64
+}
+
+// Parse reads a 1D or 3D table in the Adobe .cube format.
+func Parse(r io.Reader) (*LUT, error) {
+	l := &LUT{DomainMax: [3]float64{1, 1, 1}}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		keyword := fields[0]
+		args := fields[1:]
+
+		switch keyword {
+		case "TITLE":
+			l.Title = strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "TITLE")), `"`)
+
+		case "LUT_1D_SIZE", "LUT_3D_SIZE":
+			if l.Dimensions != 0 {
+				return nil, fmt.Errorf("line %d: table size declared twice", lineNo)
+			}
+			size, err := parseSize(args)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			l.Dimensions, l.Size = 1, size
+			limit := max1DSize
+			if keyword == "LUT_3D_SIZE" {
+				l.Dimensions, limit = 3, max3DSize
+			}
+			if size < 2 || size > limit {
+				return nil, fmt.Errorf("line %d: %s must be between 2 and %d", lineNo, keyword, limit)
+			}
+
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			values, err := parseTriple(args)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			if keyword == "DOMAIN_MIN" {
+				l.DomainMin = values
+			} else {
+				l.DomainMax = values
+			}
+
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			// Resolve-style range, the same bounds for every channel
+			if len(args) != 2 {
+				return nil, fmt.Errorf("line %d: %s expects 2 values", lineNo, keyword)
+			}
+			lo, err := parseValue(args[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			hi, err := parseValue(args[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			l.DomainMin = [3]float64{lo, lo, lo}
+			l.DomainMax = [3]float64{hi, hi, hi}
+
+		default:
+			if !isNumeric(keyword) {
+				return nil, fmt.Errorf("line %d: unknown keyword %s", lineNo, keyword)
+			}
+			if l.Dimensions == 0 {
+				return nil, fmt.Errorf("line %d: table data before LUT_1D_SIZE or LUT_3D_SIZE", lineNo)
+			}
+			values, err := parseTriple(fields)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			l.Table = append(l.Table, values)
+			if len(l.Table) > l.entries() {
+				return nil, fmt.Errorf("line %d: more table entries than declared", lineNo)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if l.Dimensions == 0 {
+		return nil, fmt.Errorf("missing LUT_1D_SIZE or LUT_3D_SIZE")
+	}
+	if len(l.Table) != l.entries() {
+		return nil, fmt.Errorf("expected %d table entries, found %d", l.entries(), len(l.Table))
+	}
+	for i := 0; i < 3; i++ {
+		if l.DomainMax[i] <= l.DomainMin[i] {
+			return nil, fmt.Errorf("DOMAIN_MAX must be greater than DOMAIN_MIN")
+		}
+	}
+
+	return l, nil
+}
+
+func (l *LUT) entries() int {
+	if l.Dimensions == 3 {
+		return l.Size * l.Size * l.Size
+	}
+	return l.Size
+}
+
+// Apply maps an RGB triple with channels in the table's domain. 3D tables use
+// trilinear interpolation, 1D tables interpolate each channel linearly.
+func (l *LUT) Apply(r, g, b float64) (float64, float64, float64) {
+	in := [3]float64{r, g, b}
+	var pos [3]float64
+	for i := range in {
+		t := (in[i] - l.DomainMin[i]) / (l.DomainMax[i] - l.DomainMin[i])
+		pos[i] = math.Min(math.Max(t, 0), 1) * float64(l.Size-1)
+	}
+
+	if l.Dimensions == 1 {
+		var out [3]float64
+		for c := 0; c < 3; c++ {
+			i0, i1, f := split(pos[c], l.Size)
+			out[c] = lerp(l.Table[i0][c], l.Table[i1][c], f)
+		}
+		return out[0], out[1], out[2]
+	}
+
+	r0, r1, fr := split(pos[0], l.Size)
+	g0, g1, fg := split(pos[1], l.Size)
+	b0, b1, fb := split(pos[2], l.Size)
+
+	at := func(ri, gi, bi int) [3]float64 {
+		return l.Table[ri+gi*l.Size+bi*l.Size*l.Size]
+	}
+
+	var out [3]float64
+	for c := 0; c < 3; c++ {
+		c00 := lerp(at(r0, g0, b0)[c], at(r1, g0, b0)[c], fr)
+		c10 := lerp(at(r0, g1, b0)[c], at(r1, g1, b0)[c], fr)
+		c01 := lerp(at(r0, g0, b1)[c], at(r1, g0, b1)[c], fr)
+		c11 := lerp(at(r0, g1, b1)[c], at(r1, g1, b1)[c], fr)
+		out[c] = lerp(lerp(c00, c10, fg), lerp(c01, c11, fg), fb)
+	}
+	return out[0], out[1], out[2]
+}
+
+func split(pos float64, size int) (int, int, float64) {
+	i0 := int(math.Floor(pos))
+	if i0 >= size-1 {
+		return size - 1, size - 1, 0
+	}
+	return i0, i0 + 1, pos - float64(i0)
+}
+
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+func parseSize(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected a single size value")
+	}
+	size, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", args[0])
+	}
+	return size, nil
+}
+
+func parseTriple(args []string) ([3]float64, error) {
+	var values [3]float64
+	if len(args) != 3 {
+		return values, fmt.Errorf("expected 3 values, found %d", len(args))
+	}
+	for i, arg := range args {
+		v, err := parseValue(arg)
+		if err != nil {
+			return values, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// parseValue parses a finite number. NaN or infinite bounds would turn table
+// positions into out-of-range indexes in Apply.
+func parseValue(arg string) (float64, error) {
+	v, err := strconv.ParseFloat(arg, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid value %q", arg)
+	}
+	return v, nil
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
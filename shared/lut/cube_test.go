@@ -0,0 +1,91 @@
+package lut
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// identity3D is a 2x2x2 identity table, red changing fastest.
+const identity3D = `0 0 0
+1 0 0
+0 1 0
+1 1 0
+0 0 1
+1 0 1
+0 1 1
+1 1 1
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		cube    string
+		wantErr bool
+	}{
+		{name: "3D", cube: "TITLE \"identity\"\nLUT_3D_SIZE 2\n" + identity3D},
+		{name: "1D", cube: "LUT_1D_SIZE 2\n0 0 0\n1 1 1\n"},
+		{name: "domain", cube: "LUT_3D_SIZE 2\nDOMAIN_MIN 0 0 0\nDOMAIN_MAX 2 2 2\n" + identity3D},
+		{name: "input range", cube: "LUT_3D_SIZE 2\nLUT_3D_INPUT_RANGE 0 2\n" + identity3D},
+		{name: "comments and blank lines", cube: "# made by hand\n\nLUT_3D_SIZE 2\n" + identity3D},
+		{name: "missing size", cube: identity3D, wantErr: true},
+		{name: "size declared twice", cube: "LUT_3D_SIZE 2\nLUT_3D_SIZE 2\n" + identity3D, wantErr: true},
+		{name: "3D size too large", cube: "LUT_3D_SIZE 65\n", wantErr: true},
+		{name: "size too small", cube: "LUT_1D_SIZE 1\n0 0 0\n", wantErr: true},
+		{name: "too few entries", cube: "LUT_3D_SIZE 2\n0 0 0\n", wantErr: true},
+		{name: "too many entries", cube: "LUT_3D_SIZE 2\n" + identity3D + "1 1 1\n", wantErr: true},
+		{name: "unknown keyword", cube: "LUT_3D_SIZE 2\nGAMMA 2.2\n" + identity3D, wantErr: true},
+		{name: "short row", cube: "LUT_1D_SIZE 2\n0 0\n1 1 1\n", wantErr: true},
+		{name: "NaN entry", cube: "LUT_1D_SIZE 2\n0 NaN 0\n1 1 1\n", wantErr: true},
+		{name: "NaN domain", cube: "LUT_3D_SIZE 2\nDOMAIN_MIN nan nan nan\n" + identity3D, wantErr: true},
+		{name: "NaN input range", cube: "LUT_3D_SIZE 2\nLUT_3D_INPUT_RANGE nan nan\n" + identity3D, wantErr: true},
+		{name: "infinite input range", cube: "LUT_1D_SIZE 2\nLUT_1D_INPUT_RANGE 0 +Inf\n0 0 0\n1 1 1\n", wantErr: true},
+		{name: "empty domain", cube: "LUT_3D_SIZE 2\nLUT_3D_INPUT_RANGE 1 1\n" + identity3D, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.cube))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	identity, err := Parse(strings.NewReader("LUT_3D_SIZE 2\n" + identity3D))
+	if err != nil {
+		t.Fatal(err)
+	}
+	invert, err := Parse(strings.NewReader("LUT_1D_SIZE 2\n1 1 1\n0 0 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := Parse(strings.NewReader("LUT_3D_SIZE 2\nLUT_3D_INPUT_RANGE 0 2\n" + identity3D))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		lut     *LUT
+		in, out [3]float64
+	}{
+		{"identity", identity, [3]float64{0.2, 0.5, 0.9}, [3]float64{0.2, 0.5, 0.9}},
+		{"1D invert", invert, [3]float64{0.25, 0.5, 1}, [3]float64{0.75, 0.5, 0}},
+		{"input range", scaled, [3]float64{1, 2, 0}, [3]float64{0.5, 1, 0}},
+		{"clamped below the domain", identity, [3]float64{-1, 0, 0}, [3]float64{0, 0, 0}},
+		{"clamped above the domain", identity, [3]float64{2, 1, 1}, [3]float64{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, g, b := tt.lut.Apply(tt.in[0], tt.in[1], tt.in[2])
+			for i, got := range [3]float64{r, g, b} {
+				if math.Abs(got-tt.out[i]) > 1e-9 {
+					t.Errorf("Apply(%v) = %v, want %v", tt.in, [3]float64{r, g, b}, tt.out)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,452 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	tagOrientation     = 0x0112
+	tagExifIFD         = 0x8769
+	tagGPSIFD          = 0x8825
+	tagThumbnailOffset = 0x0201
+	tagThumbnailLength = 0x0202
+)
+
+// privateTags are removed by PolicyScrub wherever they appear.
+var privateTags = map[uint16]string{
+	0x927C: "MakerNote",
+	0xA420: "ImageUniqueID",
+	0xA430: "CameraOwnerName",
+	0xA431: "BodySerialNumber",
+	0xA435: "LensSerialNumber",
+	0xC62F: "CameraSerialNumber",
+}
+
+var gpsTags = map[uint16]string{
+	0x00: "GPSVersionID",
+	0x01: "GPSLatitudeRef",
+	0x02: "GPSLatitude",
+	0x03: "GPSLongitudeRef",
+	0x04: "GPSLongitude",
+	0x05: "GPSAltitudeRef",
+	0x06: "GPSAltitude",
+	0x07: "GPSTimeStamp",
+	0x08: "GPSSatellites",
+	0x09: "GPSStatus",
+	0x0A: "GPSMeasureMode",
+	0x0B: "GPSDOP",
+	0x0C: "GPSSpeedRef",
+	0x0D: "GPSSpeed",
+	0x0E: "GPSTrackRef",
+	0x0F: "GPSTrack",
+	0x10: "GPSImgDirectionRef",
+	0x11: "GPSImgDirection",
+	0x12: "GPSMapDatum",
+	0x13: "GPSDestLatitudeRef",
+	0x14: "GPSDestLatitude",
+	0x15: "GPSDestLongitudeRef",
+	0x16: "GPSDestLongitude",
+	0x17: "GPSDestBearingRef",
+	0x18: "GPSDestBearing",
+	0x19: "GPSDestDistanceRef",
+	0x1A: "GPSDestDistance",
+	0x1B: "GPSProcessingMethod",
+	0x1C: "GPSAreaInformation",
+	0x1D: "GPSDateStamp",
+	0x1E: "GPSDifferential",
+	0x1F: "GPSHPositioningError",
+}
+
+// Byte sizes of the TIFF field types, indexed by type ID.
+var typeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 4}
+
+type ifdEntry struct {
+	offset int
+	tag    uint16
+	typ    uint16
+	count  uint32
+}
+
+// tiff edits a TIFF structure (the body of an EXIF block) in place.
+type tiff struct {
+	b     []byte
+	order binary.ByteOrder
+}
+
+func newTIFF(b []byte) (*tiff, error) {
+	if len(b) < 8 {
+		return nil, fmt.Errorf("EXIF block too short")
+	}
+	switch string(b[:2]) {
+	case "II":
+		return &tiff{b: b, order: binary.LittleEndian}, nil
+	case "MM":
+		return &tiff{b: b, order: binary.BigEndian}, nil
+	default:
+		return nil, fmt.Errorf("invalid EXIF byte order")
+	}
+}
+
+func (t *tiff) inRange(off, size int) bool {
+	return off >= 0 && size >= 0 && off+size <= len(t.b)
+}
+
+func (t *tiff) entries(off int) ([]ifdEntry, error) {
+	if !t.inRange(off, 2) {
+		return nil, fmt.Errorf("IFD offset %d out of range", off)
+	}
+	n := int(t.order.Uint16(t.b[off:]))
+	if !t.inRange(off+2, n*12+4) {
+		return nil, fmt.Errorf("IFD at %d is truncated", off)
+	}
+
+	entries := make([]ifdEntry, 0, n)
+	for i := 0; i < n; i++ {
+		pos := off + 2 + i*12
+		entries = append(entries, ifdEntry{
+			offset: pos,
+			tag:    t.order.Uint16(t.b[pos:]),
+			typ:    t.order.Uint16(t.b[pos+2:]),
+			count:  t.order.Uint32(t.b[pos+4:]),
+		})
+	}
+	return entries, nil
+}
+
+func (t *tiff) pointer(e ifdEntry) int {
+	return int(t.order.Uint32(t.b[e.offset+8:]))
+}
+
+// number returns the inline value of a SHORT or LONG entry.
+func (t *tiff) number(e ifdEntry) int {
+	if e.typ == 3 {
+		return int(t.order.Uint16(t.b[e.offset+8:]))
+	}
+	return t.pointer(e)
+}
+
+// wipeValue zeroes the out-of-line value of an entry, if it has one.
+func (t *tiff) wipeValue(e ifdEntry) {
+	if int(e.typ) >= len(typeSizes) {
+		return
+	}
+	size := typeSizes[e.typ] * int(e.count)
+	if size <= 4 {
+		return
+	}
+	start := t.pointer(e)
+	if t.inRange(start, size) {
+		clear(t.b[start : start+size])
+	}
+}
+
+// removeEntries drops every entry of the IFD at off for which remove returns
+// true. The remaining entries are compacted so that value offsets elsewhere
+// in the block stay valid.
+func (t *tiff) removeEntries(off int, remove func(tag uint16) bool) ([]ifdEntry, error) {
+	entries, err := t.entries(off)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept [][]byte
+	var removed []ifdEntry
+	for _, e := range entries {
+		if remove(e.tag) {
+			t.wipeValue(e)
+			removed = append(removed, e)
+			continue
+		}
+		kept = append(kept, append([]byte(nil), t.b[e.offset:e.offset+12]...))
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	end := off + 2 + len(entries)*12
+	next := append([]byte(nil), t.b[end:end+4]...)
+
+	t.order.PutUint16(t.b[off:], uint16(len(kept)))
+	pos := off + 2
+	for _, k := range kept {
+		copy(t.b[pos:], k)
+		pos += 12
+	}
+	copy(t.b[pos:], next)
+	clear(t.b[pos+4 : end+4])
+
+	return removed, nil
+}
+
+// wipeIFD zeroes a whole IFD and its values, returning the names of the
+// fields it contained.
+func (t *tiff) wipeIFD(off int, names map[uint16]string) ([]string, error) {
+	entries, err := t.entries(off)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, e := range entries {
+		t.wipeValue(e)
+		removed = append(removed, tagName(names, e.tag))
+	}
+	clear(t.b[off : off+2+len(entries)*12+4])
+	return removed, nil
+}
+
+func tagName(names map[uint16]string, tag uint16) string {
+	if name, ok := names[tag]; ok {
+		return name
+	}
+	return fmt.Sprintf("Tag0x%04X", tag)
+}
+
+// containerTags hold XMP, IPTC and Photoshop blocks inside an IFD. They are
+// removed by PolicyScrub like the corresponding JPEG segments.
+var containerTags = map[uint16]string{
+	0x02BC: "XMP",
+	0x83BB: "IPTC",
+	0x8649: "Photoshop",
+}
+
+// descriptiveTags identify the camera, software or author in a TIFF file.
+// They are removed by PolicyStrip.
+var descriptiveTags = map[uint16]string{
+	0x010E: "ImageDescription",
+	0x010F: "Make",
+	0x0110: "Model",
+	0x0131: "Software",
+	0x0132: "DateTime",
+	0x013B: "Artist",
+	0x013C: "HostComputer",
+	0x8298: "Copyright",
+}
+
+const (
+	tagICCProfile = 0x8773
+
+	// maxTIFFPages bounds the IFD chain walked in TIFF files
+	maxTIFFPages = 256
+)
+
+func removedTagName(tag uint16) string {
+	for _, names := range []map[uint16]string{privateTags, containerTags, descriptiveTags} {
+		if name, ok := names[tag]; ok {
+			return name
+		}
+	}
+	return tagName(nil, tag)
+}
+
+// scrubIFD removes the GPS IFD, private tags and embedded XMP/IPTC blocks
+// referenced from the IFD at off. With strip set, the EXIF IFD and the
+// descriptive tags are removed as well.
+func (t *tiff) scrubIFD(off int, strip bool) ([]string, error) {
+	entries, err := t.entries(off)
+	if err != nil {
+		return nil, err
+	}
+
+	isPrivate := func(tag uint16) bool {
+		_, ok := privateTags[tag]
+		return ok
+	}
+
+	var removed []string
+	for _, e := range entries {
+		switch {
+		case e.tag == tagGPSIFD:
+			names, err := t.wipeIFD(t.pointer(e), gpsTags)
+			if err != nil {
+				return nil, err
+			}
+			removed = append(removed, names...)
+		case e.tag == tagExifIFD && strip:
+			if _, err := t.wipeIFD(t.pointer(e), privateTags); err != nil {
+				return nil, err
+			}
+			removed = append(removed, "EXIF")
+		case e.tag == tagExifIFD:
+			dropped, err := t.removeEntries(t.pointer(e), isPrivate)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range dropped {
+				removed = append(removed, tagName(privateTags, d.tag))
+			}
+		}
+	}
+
+	dropped, err := t.removeEntries(off, func(tag uint16) bool {
+		_, container := containerTags[tag]
+		_, descriptive := descriptiveTags[tag]
+		return tag == tagGPSIFD || isPrivate(tag) || container ||
+			strip && (tag == tagExifIFD || descriptive)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dropped {
+		switch d.tag {
+		case tagGPSIFD:
+			removed = append(removed, "GPSInfo")
+		case tagExifIFD:
+			// Reported above
+		default:
+			removed = append(removed, removedTagName(d.tag))
+		}
+	}
+
+	return removed, nil
+}
+
+// nextIFD returns the offset of the IFD following the one at off.
+func (t *tiff) nextIFD(off int) (int, error) {
+	entries, err := t.entries(off)
+	if err != nil {
+		return 0, err
+	}
+	return int(t.order.Uint32(t.b[off+2+len(entries)*12:])), nil
+}
+
+// dropThumbnail unlinks IFD1 of an EXIF block, which holds the embedded
+// thumbnail, and zeroes it along with the thumbnail image. The thumbnail is
+// rendered from the original capture, so it can still show what was cropped
+// or redacted since. It reports whether there was a thumbnail.
+func (t *tiff) dropThumbnail(ifd0 int) (bool, error) {
+	entries, err := t.entries(ifd0)
+	if err != nil {
+		return false, err
+	}
+	link := ifd0 + 2 + len(entries)*12
+	next := int(t.order.Uint32(t.b[link:]))
+	if next == 0 {
+		return false, nil
+	}
+	clear(t.b[link : link+4])
+
+	// A malformed IFD1 is unlinked all the same
+	thumbnail, err := t.entries(next)
+	if err != nil {
+		return true, nil
+	}
+	start, length := -1, 0
+	for _, e := range thumbnail {
+		switch e.tag {
+		case tagThumbnailOffset:
+			start = t.number(e)
+		case tagThumbnailLength:
+			length = t.number(e)
+		}
+	}
+	if start >= 0 && t.inRange(start, length) {
+		clear(t.b[start : start+length])
+	}
+	t.wipeIFD(next, nil)
+	return true, nil
+}
+
+// scrubTIFF removes the GPS IFD, private tags and the thumbnail from an EXIF
+// block in place and returns the names of the removed fields. TIFF files are
+// handled by scrubTIFFFile instead, since their IFD1 is a page of the image.
+func scrubTIFF(b []byte) ([]string, error) {
+	t, err := newTIFF(b)
+	if err != nil {
+		return nil, err
+	}
+	ifd0 := int(t.order.Uint32(t.b[4:]))
+	removed, err := t.scrubIFD(ifd0, false)
+	if err != nil {
+		return nil, err
+	}
+	thumbnail, err := t.dropThumbnail(ifd0)
+	if err != nil {
+		return nil, err
+	}
+	if thumbnail {
+		removed = append(removed, "Thumbnail")
+	}
+	return removed, nil
+}
+
+// scrubTIFFFile applies policy to every page of a TIFF file. Tags describing
+// the image data are left alone, so the file still decodes.
+func scrubTIFFFile(data []byte, policy Policy, report Report) ([]byte, Report, error) {
+	out := append([]byte(nil), data...)
+	t, err := newTIFF(out)
+	if err != nil {
+		return nil, report, err
+	}
+
+	ifd0 := int(t.order.Uint32(t.b[4:]))
+	seen := map[int]bool{}
+	for off := ifd0; off != 0; {
+		if seen[off] || len(seen) == maxTIFFPages {
+			return nil, report, fmt.Errorf("TIFF IFD chain is too long or loops")
+		}
+		seen[off] = true
+
+		removed, err := t.scrubIFD(off, policy == PolicyStrip)
+		if err != nil {
+			return nil, report, err
+		}
+		for _, name := range removed {
+			report.Removed = appendUnique(report.Removed, name)
+		}
+		if off, err = t.nextIFD(off); err != nil {
+			return nil, report, err
+		}
+	}
+
+	entries, err := t.entries(ifd0)
+	if err != nil {
+		return nil, report, err
+	}
+	for _, e := range entries {
+		switch e.tag {
+		case tagExifIFD:
+			report.Preserved = appendUnique(report.Preserved, "EXIF")
+		case tagOrientation:
+			report.Preserved = appendUnique(report.Preserved, "Orientation")
+		case tagICCProfile:
+			report.Preserved = appendUnique(report.Preserved, "ICCProfile")
+		}
+	}
+	return out, report, nil
+}
+
+// minimalTIFF builds an EXIF block holding only the orientation of b.
+// It returns false when b carries no orientation worth keeping.
+func minimalTIFF(b []byte) ([]byte, bool) {
+	t, err := newTIFF(b)
+	if err != nil {
+		return nil, false
+	}
+	entries, err := t.entries(int(t.order.Uint32(t.b[4:])))
+	if err != nil {
+		return nil, false
+	}
+
+	for _, e := range entries {
+		if e.tag != tagOrientation {
+			continue
+		}
+		orientation := t.order.Uint16(t.b[e.offset+8:])
+		if orientation <= 1 || orientation > 8 {
+			return nil, false
+		}
+
+		out := make([]byte, 26)
+		copy(out, "II*\x00")
+		binary.LittleEndian.PutUint32(out[4:], 8)
+		binary.LittleEndian.PutUint16(out[8:], 1)
+		binary.LittleEndian.PutUint16(out[10:], tagOrientation)
+		binary.LittleEndian.PutUint16(out[12:], 3)
+		binary.LittleEndian.PutUint32(out[14:], 1)
+		binary.LittleEndian.PutUint16(out[18:], orientation)
+		return out, true
+	}
+	return nil, false
+}
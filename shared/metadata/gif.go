@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+)
+
+var (
+	gif87Magic = []byte("GIF87a")
+	gif89Magic = []byte("GIF89a")
+)
+
+// gifApplications are the application extensions kept by every policy.
+var gifApplications = map[string]string{
+	"NETSCAPE2.0": "", // loop count
+	"ANIMEXTS1.0": "",
+	"ICCRGBG1012": "ICCProfile",
+}
+
+// skipSubBlocks returns the offset following the data sub-blocks at pos.
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, fmt.Errorf("truncated GIF data at offset %d", pos)
+		}
+		n := int(data[pos])
+		pos++
+		if n == 0 {
+			return pos, nil
+		}
+		pos += n
+	}
+}
+
+// scrubGIF removes comment extensions and application extensions such as
+// XMP. GIF has no EXIF, so scrub and strip are the same.
+func scrubGIF(data []byte, policy Policy, report Report) ([]byte, Report, error) {
+	if len(data) < 13 {
+		return nil, report, fmt.Errorf("truncated GIF header")
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&7 + 1)
+	}
+	if pos > len(data) {
+		return nil, report, fmt.Errorf("truncated GIF color table")
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:pos])
+
+	for {
+		if pos >= len(data) {
+			return nil, report, fmt.Errorf("truncated GIF: no trailer found")
+		}
+
+		switch data[pos] {
+		case 0x3B: // trailer
+			out.WriteByte(0x3B)
+			return out.Bytes(), report, nil
+
+		case 0x2C: // image descriptor
+			start := pos
+			if pos+10 > len(data) {
+				return nil, report, fmt.Errorf("truncated GIF image descriptor at offset %d", pos)
+			}
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&7 + 1)
+			}
+			end, err := skipSubBlocks(data, pos+1) // after the LZW code size
+			if err != nil {
+				return nil, report, err
+			}
+			out.Write(data[start:end])
+			pos = end
+
+		case 0x21: // extension
+			if pos+2 > len(data) {
+				return nil, report, fmt.Errorf("truncated GIF extension at offset %d", pos)
+			}
+			end, err := skipSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, report, err
+			}
+			block := data[pos:end]
+			pos = end
+
+			switch block[1] {
+			case 0xFE:
+				report.Removed = appendUnique(report.Removed, "Comment")
+				continue
+			case 0xFF:
+				var app string
+				if len(block) >= 14 && block[2] == 11 {
+					app = string(block[3:14])
+				}
+				preserved, ok := gifApplications[app]
+				switch {
+				case app == "XMP DataXMP":
+					report.Removed = appendUnique(report.Removed, "XMP")
+					continue
+				case !ok:
+					report.Removed = appendUnique(report.Removed, "ApplicationExtension")
+					continue
+				case preserved != "":
+					report.Preserved = appendUnique(report.Preserved, preserved)
+				}
+			}
+			out.Write(block)
+
+		default:
+			return nil, report, fmt.Errorf("invalid GIF block 0x%02X at offset %d", data[pos], pos)
+		}
+	}
+}
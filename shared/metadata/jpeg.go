@@ -0,0 +1,177 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type segment struct {
+	marker byte
+	data   []byte // payload without marker and length
+	raw    []byte
+}
+
+func (s segment) kind() string {
+	switch {
+	case s.marker == 0xE1 && bytes.HasPrefix(s.data, exifHeader):
+		return "EXIF"
+	case s.marker == 0xE1 && (bytes.HasPrefix(s.data, xmpHeader) || bytes.HasPrefix(s.data, xmpExtHeader)):
+		return "XMP"
+	case s.marker == 0xE2 && bytes.HasPrefix(s.data, iccHeader):
+		return "ICCProfile"
+	case s.marker == 0xED && bytes.HasPrefix(s.data, photoshopHead):
+		return "IPTC"
+	case s.marker == 0xFE:
+		return "Comment"
+	case s.marker >= 0xE0 && s.marker <= 0xEF:
+		return fmt.Sprintf("APP%d", s.marker-0xE0)
+	default:
+		return ""
+	}
+}
+
+// splitJPEG returns the segments preceding the image data and the remaining
+// bytes, starting at the SOS marker.
+func splitJPEG(data []byte) ([]segment, []byte, error) {
+	var segments []segment
+	pos := 2
+	for pos+1 < len(data) {
+		if data[pos] != 0xFF {
+			return nil, nil, fmt.Errorf("invalid JPEG marker at offset %d", pos)
+		}
+		// Skip fill bytes
+		if data[pos+1] == 0xFF {
+			pos++
+			continue
+		}
+
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return segments, data[pos:], nil
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			segments = append(segments, segment{marker: marker, raw: data[pos : pos+2]})
+			pos += 2
+			continue
+		}
+
+		if pos+4 > len(data) {
+			break
+		}
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, nil, fmt.Errorf("invalid JPEG segment length at offset %d", pos)
+		}
+		segments = append(segments, segment{marker: marker, data: data[pos+4 : end], raw: data[pos:end]})
+		pos = end
+	}
+	return nil, nil, fmt.Errorf("truncated JPEG: no image data found")
+}
+
+func writeSegment(buf *bytes.Buffer, marker byte, payload []byte) {
+	length := len(payload) + 2
+	buf.Write([]byte{0xFF, marker, byte(length >> 8), byte(length)})
+	buf.Write(payload)
+}
+
+func scrubJPEG(data []byte, policy Policy, report Report) ([]byte, Report, error) {
+	segments, rest, err := splitJPEG(data)
+	if err != nil {
+		return nil, report, err
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:2])
+
+	for _, s := range segments {
+		kind := s.kind()
+		switch kind {
+		case "EXIF":
+			body := append([]byte(nil), s.data[len(exifHeader):]...)
+			if policy == PolicyStrip {
+				report.Removed = appendUnique(report.Removed, "EXIF")
+				if minimal, ok := minimalTIFF(body); ok {
+					writeSegment(&out, s.marker, append(append([]byte(nil), exifHeader...), minimal...))
+					report.Preserved = appendUnique(report.Preserved, "Orientation")
+				}
+				continue
+			}
+
+			removed, err := scrubTIFF(body)
+			if err != nil {
+				// Unreadable EXIF cannot be scrubbed selectively, so drop it
+				report.Removed = appendUnique(report.Removed, "EXIF")
+				continue
+			}
+			for _, name := range removed {
+				report.Removed = appendUnique(report.Removed, name)
+			}
+			report.Preserved = appendUnique(report.Preserved, "EXIF")
+			writeSegment(&out, s.marker, append(append([]byte(nil), exifHeader...), body...))
+
+		case "XMP", "IPTC":
+			report.Removed = appendUnique(report.Removed, kind)
+
+		case "ICCProfile":
+			report.Preserved = appendUnique(report.Preserved, kind)
+			out.Write(s.raw)
+
+		case "", "APP0", "APP14":
+			// Table segments and the JFIF/Adobe markers are needed for decoding
+			out.Write(s.raw)
+
+		default:
+			if policy == PolicyStrip {
+				report.Removed = appendUnique(report.Removed, kind)
+				continue
+			}
+			out.Write(s.raw)
+		}
+	}
+
+	out.Write(rest)
+	return out.Bytes(), report, nil
+}
+
+func transferJPEG(src, dst []byte, keepAll bool) ([]byte, error) {
+	srcSegments, _, err := splitJPEG(src)
+	if err != nil {
+		return nil, err
+	}
+	dstSegments, rest, err := splitJPEG(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	var carried [][]byte
+	for _, s := range srcSegments {
+		switch s.kind() {
+		case "ICCProfile":
+			carried = append(carried, s.raw)
+		case "EXIF", "XMP", "IPTC":
+			if keepAll {
+				carried = append(carried, s.raw)
+			}
+		}
+	}
+	if len(carried) == 0 {
+		return dst, nil
+	}
+
+	var out bytes.Buffer
+	out.Write(dst[:2])
+	// Keep a leading JFIF marker in first position
+	if len(dstSegments) > 0 && dstSegments[0].kind() == "APP0" {
+		out.Write(dstSegments[0].raw)
+		dstSegments = dstSegments[1:]
+	}
+	for _, raw := range carried {
+		out.Write(raw)
+	}
+	for _, s := range dstSegments {
+		out.Write(s.raw)
+	}
+	out.Write(rest)
+	return out.Bytes(), nil
+}
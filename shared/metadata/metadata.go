@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Policy controls how much metadata survives an upload.
+type Policy string
+
+const (
+	// PolicyKeep stores the file exactly as uploaded.
+	PolicyKeep Policy = "keep"
+	// PolicyScrub removes GPS, serial-number and owner fields plus XMP/IPTC
+	// blocks, keeping the rest of the EXIF data.
+	PolicyScrub Policy = "scrub"
+	// PolicyStrip removes everything except orientation and the color profile.
+	PolicyStrip Policy = "strip"
+)
+
+// Report lists the metadata fields that were removed or deliberately kept.
+type Report struct {
+	Policy    Policy   `json:"policy"`
+	Removed   []string `json:"removed"`
+	Preserved []string `json:"preserved"`
+}
+
+var (
+	jpegMagic   = []byte{0xFF, 0xD8, 0xFF}
+	pngMagic    = []byte("\x89PNG\r\n\x1a\n")
+	tiffLEMagic = []byte("II*\x00")
+	tiffBEMagic = []byte("MM\x00*")
+	bmpMagic    = []byte("BM")
+
+	exifHeader    = []byte("Exif\x00\x00")
+	xmpHeader     = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	xmpExtHeader  = []byte("http://ns.adobe.com/xmp/extension/\x00")
+	iccHeader     = []byte("ICC_PROFILE\x00")
+	photoshopHead = []byte("Photoshop 3.0\x00")
+)
+
+// ParsePolicy validates a policy name. An empty name selects PolicyScrub.
+func ParsePolicy(name string) (Policy, error) {
+	switch Policy(name) {
+	case "":
+		return PolicyScrub, nil
+	case PolicyKeep, PolicyScrub, PolicyStrip:
+		return Policy(name), nil
+	default:
+		return "", fmt.Errorf("unknown metadata policy: %s (supported: keep, scrub, strip)", name)
+	}
+}
+
+// Scrub applies policy to an image file and returns the cleaned bytes. It
+// fails for formats whose metadata it cannot inspect rather than reporting
+// a file as clean.
+func Scrub(data []byte, policy Policy) ([]byte, Report, error) {
+	report := Report{Policy: policy, Removed: []string{}, Preserved: []string{}}
+	if policy == PolicyKeep {
+		return data, report, nil
+	}
+
+	switch {
+	case bytes.HasPrefix(data, jpegMagic):
+		return scrubJPEG(data, policy, report)
+	case bytes.HasPrefix(data, pngMagic):
+		return scrubPNG(data, policy, report)
+	case bytes.HasPrefix(data, tiffLEMagic) || bytes.HasPrefix(data, tiffBEMagic):
+		return scrubTIFFFile(data, policy, report)
+	case isWebP(data):
+		return scrubWebP(data, policy, report)
+	case bytes.HasPrefix(data, gif87Magic) || bytes.HasPrefix(data, gif89Magic):
+		return scrubGIF(data, policy, report)
+	case bytes.HasPrefix(data, bmpMagic):
+		// BMP has no metadata blocks, only an optional color profile
+		return data, report, nil
+	default:
+		return nil, report, fmt.Errorf("cannot inspect metadata of this format, upload with the keep policy to store it as is")
+	}
+}
+
+// Transfer copies metadata from src into dst when both are the same
+// container format. With keepAll false only the color profile is copied;
+// otherwise EXIF, XMP and IPTC blocks are carried over as well.
+func Transfer(src, dst []byte, keepAll bool) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(src, jpegMagic) && bytes.HasPrefix(dst, jpegMagic):
+		return transferJPEG(src, dst, keepAll)
+	case bytes.HasPrefix(src, pngMagic) && bytes.HasPrefix(dst, pngMagic):
+		return transferPNG(src, dst, keepAll)
+	default:
+		return dst, nil
+	}
+}
+
+func appendUnique(list []string, name string) []string {
+	for _, existing := range list {
+		if existing == name {
+			return list
+		}
+	}
+	return append(list, name)
+}
@@ -0,0 +1,470 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"slices"
+	"testing"
+)
+
+// ifdTag describes a TIFF field for the fixtures. Values longer than four
+// bytes are written out of line; tags with sub set point to a nested IFD.
+type ifdTag struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+	sub      []ifdTag
+}
+
+func ascii(s string) []byte { return append([]byte(s), 0) }
+
+// writeIFD appends a little-endian IFD and its values to b and returns its
+// offset. next, if set, is written as the following IFD in the chain.
+func writeIFD(b *[]byte, tags []ifdTag, next []ifdTag) int {
+	le := binary.LittleEndian
+	off := len(*b)
+	*b = append(*b, make([]byte, 2+len(tags)*12+4)...)
+	le.PutUint16((*b)[off:], uint16(len(tags)))
+
+	for i, t := range tags {
+		pos := off + 2 + i*12
+		le.PutUint16((*b)[pos:], t.tag)
+		le.PutUint16((*b)[pos+2:], t.typ)
+		le.PutUint32((*b)[pos+4:], t.count)
+		switch {
+		case t.sub != nil:
+			sub := writeIFD(b, t.sub, nil)
+			le.PutUint32((*b)[pos+8:], uint32(sub))
+		case len(t.value) <= 4:
+			copy((*b)[pos+8:pos+12], t.value)
+		default:
+			le.PutUint32((*b)[pos+8:], uint32(len(*b)))
+			*b = append(*b, t.value...)
+		}
+	}
+	if next != nil {
+		n := writeIFD(b, next, nil)
+		le.PutUint32((*b)[off+2+len(tags)*12:], uint32(n))
+	}
+	return off
+}
+
+func buildTIFF(ifd0, ifd1 []ifdTag) []byte {
+	b := []byte("II*\x00\x08\x00\x00\x00")
+	writeIFD(&b, ifd0, ifd1)
+	return b
+}
+
+// Recognizable values that must not survive scrubbing
+const (
+	secretGPS    = "GPSGPSGPSGPSGPSGPSGPSGPS"
+	secretSerial = "SERIAL-12345"
+	secretXMP    = "<x:xmpmeta secret/>"
+	cameraMake   = "CameraMake"
+)
+
+func privateIFD0(orientation byte) []ifdTag {
+	return []ifdTag{
+		{tag: 0x010F, typ: 2, count: uint32(len(cameraMake) + 1), value: ascii(cameraMake)},
+		{tag: tagOrientation, typ: 3, count: 1, value: []byte{orientation, 0}},
+		{tag: 0x02BC, typ: 1, count: uint32(len(secretXMP)), value: []byte(secretXMP)},
+		{tag: tagExifIFD, typ: 4, count: 1, sub: []ifdTag{
+			{tag: 0xA431, typ: 2, count: uint32(len(secretSerial) + 1), value: ascii(secretSerial)},
+			{tag: 0x829A, typ: 5, count: 1, value: []byte{1, 0, 0, 0, 100, 0, 0, 0}},
+		}},
+		{tag: tagGPSIFD, typ: 4, count: 1, sub: []ifdTag{
+			{tag: 0x02, typ: 5, count: 3, value: []byte(secretGPS)},
+		}},
+	}
+}
+
+func assertScrubbed(t *testing.T, out []byte, secrets ...string) {
+	t.Helper()
+	for _, s := range secrets {
+		if bytes.Contains(out, []byte(s)) {
+			t.Errorf("output still contains %q", s)
+		}
+	}
+}
+
+func assertListed(t *testing.T, list []string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if !slices.Contains(list, name) {
+			t.Errorf("%q not listed in %v", name, list)
+		}
+	}
+}
+
+func TestScrubTIFFFile(t *testing.T) {
+	page2 := []ifdTag{{tag: 0x010F, typ: 2, count: uint32(len(cameraMake) + 1), value: ascii(cameraMake)}}
+
+	tests := []struct {
+		policy    Policy
+		secrets   []string
+		removed   []string
+		preserved []string
+	}{
+		{
+			policy:    PolicyScrub,
+			secrets:   []string{secretGPS, secretSerial, secretXMP},
+			removed:   []string{"GPSLatitude", "GPSInfo", "BodySerialNumber", "XMP"},
+			preserved: []string{"EXIF", "Orientation"},
+		},
+		{
+			policy:    PolicyStrip,
+			secrets:   []string{secretGPS, secretSerial, secretXMP, cameraMake},
+			removed:   []string{"GPSInfo", "EXIF", "XMP", "Make"},
+			preserved: []string{"Orientation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			data := buildTIFF(privateIFD0(6), page2)
+			out, report, err := Scrub(data, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) != len(data) {
+				t.Errorf("TIFF size changed from %d to %d", len(data), len(out))
+			}
+			assertScrubbed(t, out, tt.secrets...)
+			assertListed(t, report.Removed, tt.removed...)
+			assertListed(t, report.Preserved, tt.preserved...)
+			if tt.policy == PolicyScrub && !bytes.Contains(out, []byte(cameraMake)) {
+				t.Error("scrub removed the camera make")
+			}
+		})
+	}
+}
+
+func buildWebP(chunks ...riffChunk) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range chunks {
+		writeRIFFChunk(&buf, c.fourCC, c.data)
+	}
+	b := buf.Bytes()
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+func TestScrubWebP(t *testing.T) {
+	tests := []struct {
+		policy    Policy
+		exif      []byte
+		keepsEXIF bool
+		removed   []string
+		preserved []string
+	}{
+		{PolicyScrub, buildTIFF(privateIFD0(1), nil), true, []string{"GPSInfo", "BodySerialNumber", "XMP"}, []string{"EXIF", "ICCProfile"}},
+		{PolicyScrub, append([]byte("Exif\x00\x00"), buildTIFF(privateIFD0(1), nil)...), true, []string{"GPSInfo", "XMP"}, []string{"EXIF"}},
+		{PolicyStrip, buildTIFF(privateIFD0(6), nil), true, []string{"EXIF", "XMP"}, []string{"Orientation"}},
+		{PolicyStrip, buildTIFF(privateIFD0(1), nil), false, []string{"EXIF", "XMP"}, []string{"ICCProfile"}},
+	}
+
+	for _, tt := range tests {
+		vp8x := make([]byte, 10)
+		vp8x[0] = 0x20 | vp8xFlagEXIF | vp8xFlagXMP
+		data := buildWebP(
+			riffChunk{"VP8X", vp8x},
+			riffChunk{"ICCP", []byte("icc")},
+			riffChunk{"VP8L", []byte("pixels")},
+			riffChunk{"EXIF", tt.exif},
+			riffChunk{"XMP ", []byte(secretXMP)},
+		)
+
+		out, report, err := Scrub(data, tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertScrubbed(t, out, secretGPS, secretSerial, secretXMP)
+		assertListed(t, report.Removed, tt.removed...)
+		assertListed(t, report.Preserved, tt.preserved...)
+
+		if size := binary.LittleEndian.Uint32(out[4:]); int(size) != len(out)-8 {
+			t.Errorf("RIFF size %d, want %d", size, len(out)-8)
+		}
+		chunks, err := splitWebP(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fourCCs []string
+		for _, c := range chunks {
+			fourCCs = append(fourCCs, c.fourCC)
+		}
+		if slices.Contains(fourCCs, "EXIF") != tt.keepsEXIF {
+			t.Errorf("%s: chunks %v, EXIF kept should be %v", tt.policy, fourCCs, tt.keepsEXIF)
+		}
+		if flags := chunks[0].data[0]; flags&vp8xFlagXMP != 0 || (flags&vp8xFlagEXIF != 0) != tt.keepsEXIF {
+			t.Errorf("VP8X flags %08b do not match the remaining chunks %v", flags, fourCCs)
+		}
+	}
+}
+
+func gifExtension(label byte, payload ...[]byte) []byte {
+	b := []byte{0x21, label}
+	for _, p := range payload {
+		b = append(b, byte(len(p)))
+		b = append(b, p...)
+	}
+	return append(b, 0)
+}
+
+func TestScrubGIF(t *testing.T) {
+	var buf bytes.Buffer
+	img := image.NewPaletted(image.Rect(0, 0, 2, 2), color.Palette{color.Black, color.White})
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	header := 13 + 3<<(encoded[10]&7+1)
+
+	var data []byte
+	data = append(data, encoded[:header]...)
+	data = append(data, gifExtension(0xFF, []byte("NETSCAPE2.0"), []byte{1, 0, 0})...)
+	data = append(data, gifExtension(0xFE, []byte("taken at home"))...)
+	data = append(data, gifExtension(0xFF, []byte("XMP DataXMP"), []byte(secretXMP))...)
+	data = append(data, gifExtension(0xFF, []byte("OTHERAPP1.0"), []byte("private"))...)
+	data = append(data, encoded[header:]...)
+
+	out, report, err := Scrub(data, PolicyScrub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertScrubbed(t, out, "taken at home", secretXMP, "private")
+	assertListed(t, report.Removed, "Comment", "XMP", "ApplicationExtension")
+	if !bytes.Contains(out, []byte("NETSCAPE2.0")) {
+		t.Error("loop count extension was removed")
+	}
+	if _, err := gif.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("scrubbed GIF does not decode: %v", err)
+	}
+}
+
+func TestScrubRejectsUnknownFormats(t *testing.T) {
+	if _, _, err := Scrub([]byte("not an image at all"), PolicyScrub); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, _, err := Scrub([]byte("not an image at all"), PolicyKeep); err != nil {
+		t.Errorf("keep policy should store anything: %v", err)
+	}
+}
+
+const secretThumbnail = "THUMBNAIL-PIXELS"
+
+// exifWithThumbnail returns an EXIF block whose IFD1 points at a thumbnail.
+func exifWithThumbnail() []byte {
+	b := buildTIFF(privateIFD0(6), []ifdTag{
+		{tag: 0x0103, typ: 3, count: 1, value: []byte{6, 0}},
+		{tag: tagThumbnailOffset, typ: 4, count: 1, value: make([]byte, 4)},
+		{tag: tagThumbnailLength, typ: 4, count: 1, value: make([]byte, 4)},
+	})
+
+	// Append the thumbnail and patch its offset and length into IFD1
+	t, _ := newTIFF(b)
+	ifd1, _ := t.nextIFD(int(binary.LittleEndian.Uint32(b[4:])))
+	entries, _ := t.entries(ifd1)
+	binary.LittleEndian.PutUint32(b[entries[1].offset+8:], uint32(len(b)))
+	binary.LittleEndian.PutUint32(b[entries[2].offset+8:], uint32(len(secretThumbnail)))
+	return append(b, secretThumbnail...)
+}
+
+func testJPEG(t *testing.T, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// Insert after SOI and the JFIF APP0 segment written by the encoder
+	var out []byte
+	out = append(out, encoded[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, encoded[2:]...)
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	var buf bytes.Buffer
+	writeSegment(&buf, marker, payload)
+	return buf.Bytes()
+}
+
+func TestScrubJPEG(t *testing.T) {
+	exif := append(append([]byte(nil), exifHeader...), exifWithThumbnail()...)
+	xmp := append(append([]byte(nil), xmpHeader...), secretXMP...)
+	data := testJPEG(t,
+		jpegSegment(0xE1, exif),
+		jpegSegment(0xE1, xmp),
+		jpegSegment(0xFE, []byte("taken at home")),
+	)
+
+	tests := []struct {
+		policy    Policy
+		secrets   []string
+		removed   []string
+		preserved []string
+	}{
+		{PolicyScrub, []string{secretGPS, secretSerial, secretXMP, secretThumbnail}, []string{"GPSInfo", "BodySerialNumber", "XMP", "Thumbnail"}, []string{"EXIF"}},
+		{PolicyStrip, []string{secretGPS, secretSerial, secretXMP, secretThumbnail, cameraMake, "taken at home"}, []string{"EXIF", "XMP", "Comment"}, []string{"Orientation"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			out, report, err := Scrub(data, tt.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertScrubbed(t, out, tt.secrets...)
+			assertListed(t, report.Removed, tt.removed...)
+			assertListed(t, report.Preserved, tt.preserved...)
+			if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+				t.Errorf("scrubbed JPEG does not decode: %v", err)
+			}
+		})
+	}
+}
+
+func testPNG(t *testing.T, chunks ...riffChunk) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := splitPNG(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	out.Write(pngMagic)
+	for _, c := range parsed {
+		out.Write(c.raw)
+		if c.typ == "IHDR" {
+			for _, extra := range chunks {
+				writeChunk(&out, extra.fourCC, extra.data)
+			}
+		}
+	}
+	return out.Bytes()
+}
+
+func TestScrubPNG(t *testing.T) {
+	data := testPNG(t,
+		riffChunk{"eXIf", exifWithThumbnail()},
+		riffChunk{"iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), secretXMP...)},
+		riffChunk{"tEXt", []byte("Comment\x00taken at home")},
+	)
+
+	out, report, err := Scrub(data, PolicyScrub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertScrubbed(t, out, secretGPS, secretSerial, secretXMP, secretThumbnail)
+	assertListed(t, report.Removed, "GPSInfo", "BodySerialNumber", "XMP", "Thumbnail")
+	if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("scrubbed PNG does not decode: %v", err)
+	}
+}
+
+func TestScrubMalformed(t *testing.T) {
+	le := binary.LittleEndian
+	exifBlock := func(tiff []byte) []byte {
+		return jpegSegment(0xE1, append(append([]byte(nil), exifHeader...), tiff...))
+	}
+
+	badIFD0 := buildTIFF(privateIFD0(1), nil)
+	le.PutUint32(badIFD0[4:], 0xFFFFFF00)
+
+	hugeCount := buildTIFF(privateIFD0(1), nil)
+	le.PutUint16(hugeCount[8:], 0xFFFF)
+
+	badGPS := buildTIFF([]ifdTag{{tag: tagGPSIFD, typ: 4, count: 1, value: []byte{0xF0, 0xFF, 0xFF, 0x7F}}}, nil)
+
+	badValue := buildTIFF([]ifdTag{{tag: 0xA431, typ: 2, count: 0xFFFFFFFF, value: []byte{0x10, 0, 0, 0}}}, nil)
+
+	badThumbnail := exifWithThumbnail()
+	thumb, _ := newTIFF(badThumbnail)
+	ifd1, _ := thumb.nextIFD(8)
+	le.PutUint32(badThumbnail[ifd1+2+12+8:], 0xFFFFFF00)
+
+	loop := buildTIFF(privateIFD0(1), nil)
+	le.PutUint32(loop[8+2+5*12:], 8)
+
+	pngTruncated := testPNG(t)
+	pngTruncated = pngTruncated[:len(pngTruncated)-6]
+
+	pngLength := testPNG(t)
+	le.PutUint32(pngLength[8:], 0x7FFFFFFF)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+		removed string // reported when the block is dropped rather than parsed
+	}{
+		{name: "JPEG segment past end", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E', 'x'}, wantErr: true},
+		{name: "JPEG segment length below 2", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x01, 0xFF, 0xDA}, wantErr: true},
+		{name: "JPEG without image data", data: []byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x04, 'h', 'i'}, wantErr: true},
+		{name: "JPEG garbage between segments", data: []byte{0xFF, 0xD8, 0xFF, 0xFE, 0x00, 0x02, 0x00, 0xFF, 0xDA}, wantErr: true},
+		{name: "EXIF IFD0 offset out of range", data: testJPEG(t, exifBlock(badIFD0)), removed: "EXIF"},
+		{name: "EXIF IFD0 entry count past end", data: testJPEG(t, exifBlock(hugeCount)), removed: "EXIF"},
+		{name: "EXIF GPS offset out of range", data: testJPEG(t, exifBlock(badGPS)), removed: "EXIF"},
+		{name: "EXIF value offset out of range", data: testJPEG(t, exifBlock(badValue)), removed: "BodySerialNumber"},
+		{name: "EXIF thumbnail offset out of range", data: testJPEG(t, exifBlock(badThumbnail)), removed: "Thumbnail"},
+		{name: "EXIF block too short", data: testJPEG(t, exifBlock([]byte("II*"))), removed: "EXIF"},
+		{name: "PNG truncated chunk", data: pngTruncated, wantErr: true},
+		{name: "PNG chunk length past end", data: pngLength, wantErr: true},
+		{name: "TIFF IFD0 offset out of range", data: badIFD0, wantErr: true},
+		{name: "TIFF IFD entry count past end", data: hugeCount, wantErr: true},
+		{name: "TIFF IFD chain loops", data: loop, wantErr: true},
+		{name: "TIFF header only", data: []byte("II*\x00"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, report, err := Scrub(tt.data, PolicyScrub)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.removed != "" {
+				assertListed(t, report.Removed, tt.removed)
+			}
+		})
+	}
+}
+
+// Every prefix of a valid file must fail cleanly or scrub, never panic.
+func TestScrubTruncated(t *testing.T) {
+	exif := append(append([]byte(nil), exifHeader...), exifWithThumbnail()...)
+	vp8x := make([]byte, 10)
+	vp8x[0] = vp8xFlagEXIF
+
+	fixtures := map[string][]byte{
+		"jpeg": testJPEG(t, jpegSegment(0xE1, exif), jpegSegment(0xFE, []byte("comment"))),
+		"png":  testPNG(t, riffChunk{"eXIf", exifWithThumbnail()}),
+		"tiff": buildTIFF(privateIFD0(6), privateIFD0(1)),
+		"webp": buildWebP(riffChunk{"VP8X", vp8x}, riffChunk{"EXIF", exifWithThumbnail()}, riffChunk{"VP8L", []byte("pixels")}),
+	}
+	for name, data := range fixtures {
+		for n := range len(data) {
+			for _, policy := range []Policy{PolicyScrub, PolicyStrip} {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("%s truncated to %d bytes (%s): panic: %v", name, n, policy, r)
+						}
+					}()
+					Scrub(data[:n], policy)
+				}()
+			}
+		}
+	}
+}
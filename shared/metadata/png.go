@@ -0,0 +1,151 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+type chunk struct {
+	typ  string
+	data []byte
+	raw  []byte
+}
+
+func (c chunk) isText() bool {
+	return c.typ == "tEXt" || c.typ == "zTXt" || c.typ == "iTXt"
+}
+
+func (c chunk) isXMP() bool {
+	return c.isText() && bytes.HasPrefix(c.data, []byte("XML:com.adobe.xmp\x00"))
+}
+
+func splitPNG(data []byte) ([]chunk, error) {
+	var chunks []chunk
+	pos := len(pngMagic)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated PNG chunk at offset %d", pos)
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if end > len(data) {
+			return nil, fmt.Errorf("invalid PNG chunk length at offset %d", pos)
+		}
+		chunks = append(chunks, chunk{
+			typ:  string(data[pos+4 : pos+8]),
+			data: data[pos+8 : pos+8+length],
+			raw:  data[pos:end],
+		})
+		pos = end
+	}
+	return chunks, nil
+}
+
+func writeChunk(buf *bytes.Buffer, typ string, data []byte) {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+	copy(header[4:], typ)
+	buf.Write(header[:])
+	buf.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	binary.Write(buf, binary.BigEndian, crc.Sum32())
+}
+
+func scrubPNG(data []byte, policy Policy, report Report) ([]byte, Report, error) {
+	chunks, err := splitPNG(data)
+	if err != nil {
+		return nil, report, err
+	}
+
+	var out bytes.Buffer
+	out.Write(pngMagic)
+
+	for _, c := range chunks {
+		switch {
+		case c.typ == "eXIf":
+			body := append([]byte(nil), c.data...)
+			if policy == PolicyStrip {
+				report.Removed = appendUnique(report.Removed, "EXIF")
+				if minimal, ok := minimalTIFF(body); ok {
+					writeChunk(&out, c.typ, minimal)
+					report.Preserved = appendUnique(report.Preserved, "Orientation")
+				}
+				continue
+			}
+
+			removed, err := scrubTIFF(body)
+			if err != nil {
+				report.Removed = appendUnique(report.Removed, "EXIF")
+				continue
+			}
+			for _, name := range removed {
+				report.Removed = appendUnique(report.Removed, name)
+			}
+			report.Preserved = appendUnique(report.Preserved, "EXIF")
+			writeChunk(&out, c.typ, body)
+
+		case c.isXMP():
+			report.Removed = appendUnique(report.Removed, "XMP")
+
+		case c.isText() || c.typ == "tIME":
+			if policy == PolicyStrip {
+				report.Removed = appendUnique(report.Removed, c.typ)
+				continue
+			}
+			out.Write(c.raw)
+
+		case c.typ == "iCCP":
+			report.Preserved = appendUnique(report.Preserved, "ICCProfile")
+			out.Write(c.raw)
+
+		default:
+			out.Write(c.raw)
+		}
+	}
+
+	return out.Bytes(), report, nil
+}
+
+func transferPNG(src, dst []byte, keepAll bool) ([]byte, error) {
+	srcChunks, err := splitPNG(src)
+	if err != nil {
+		return nil, err
+	}
+	dstChunks, err := splitPNG(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	var carried [][]byte
+	for _, c := range srcChunks {
+		switch {
+		case c.typ == "iCCP" || c.typ == "sRGB" || c.typ == "gAMA" || c.typ == "cHRM":
+			carried = append(carried, c.raw)
+		case c.typ == "eXIf" || c.isText():
+			if keepAll {
+				carried = append(carried, c.raw)
+			}
+		}
+	}
+	if len(carried) == 0 {
+		return dst, nil
+	}
+
+	var out bytes.Buffer
+	out.Write(pngMagic)
+	for _, c := range dstChunks {
+		out.Write(c.raw)
+		// Ancillary color chunks must precede PLTE and IDAT
+		if c.typ == "IHDR" {
+			for _, raw := range carried {
+				out.Write(raw)
+			}
+		}
+	}
+	return out.Bytes(), nil
+}
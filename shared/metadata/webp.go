@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// VP8X feature flags announcing optional chunks
+const (
+	vp8xFlagXMP  = 0x04
+	vp8xFlagEXIF = 0x08
+)
+
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+type riffChunk struct {
+	fourCC string
+	data   []byte
+}
+
+func splitWebP(data []byte) ([]riffChunk, error) {
+	var chunks []riffChunk
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, fmt.Errorf("truncated WebP chunk at offset %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + size
+		if size < 0 || end > len(data) {
+			return nil, fmt.Errorf("invalid WebP chunk size at offset %d", pos)
+		}
+		chunks = append(chunks, riffChunk{fourCC: string(data[pos : pos+4]), data: data[pos+8 : end]})
+		// Chunks are padded to an even size
+		pos = end + size&1
+	}
+	return chunks, nil
+}
+
+func writeRIFFChunk(buf *bytes.Buffer, fourCC string, data []byte) {
+	var header [8]byte
+	copy(header[:4], fourCC)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(data)))
+	buf.Write(header[:])
+	buf.Write(data)
+	if len(data)&1 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func scrubWebP(data []byte, policy Policy, report Report) ([]byte, Report, error) {
+	chunks, err := splitWebP(data)
+	if err != nil {
+		return nil, report, err
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:12])
+
+	vp8x := -1
+	var keptEXIF bool
+	for _, c := range chunks {
+		switch c.fourCC {
+		case "EXIF":
+			// Some writers keep the JPEG "Exif\0\0" prefix
+			body := append([]byte(nil), bytes.TrimPrefix(c.data, exifHeader)...)
+			if policy == PolicyStrip {
+				report.Removed = appendUnique(report.Removed, "EXIF")
+				if minimal, ok := minimalTIFF(body); ok {
+					writeRIFFChunk(&out, c.fourCC, minimal)
+					report.Preserved = appendUnique(report.Preserved, "Orientation")
+					keptEXIF = true
+				}
+				continue
+			}
+
+			removed, err := scrubTIFF(body)
+			if err != nil {
+				report.Removed = appendUnique(report.Removed, "EXIF")
+				continue
+			}
+			for _, name := range removed {
+				report.Removed = appendUnique(report.Removed, name)
+			}
+			report.Preserved = appendUnique(report.Preserved, "EXIF")
+			writeRIFFChunk(&out, c.fourCC, body)
+			keptEXIF = true
+
+		case "XMP ":
+			report.Removed = appendUnique(report.Removed, "XMP")
+
+		case "ICCP":
+			report.Preserved = appendUnique(report.Preserved, "ICCProfile")
+			writeRIFFChunk(&out, c.fourCC, c.data)
+
+		case "VP8X":
+			vp8x = out.Len() + 8
+			writeRIFFChunk(&out, c.fourCC, c.data)
+
+		default:
+			writeRIFFChunk(&out, c.fourCC, c.data)
+		}
+	}
+
+	b := out.Bytes()
+	if vp8x >= 0 && vp8x < len(b) {
+		b[vp8x] &^= vp8xFlagXMP
+		if !keptEXIF {
+			b[vp8x] &^= vp8xFlagEXIF
+		}
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b, report, nil
+}
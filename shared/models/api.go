@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+}
+
+type BackendHealthResponse struct {
+	Status        string `json:"status"`
+	QueueSize     int    `json:"queue_size"`
+	QueueCapacity int    `json:"queue_capacity"`
+}
+
+// SignRequest asks for a signed URL for a download or transformation path.
+type SignRequest struct {
+	Path      string `json:"path"`
+	ExpiresIn int    `json:"expires_in,omitempty"` // seconds
+}
+
+type SignResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type SchemaListResponse struct {
+	Schemas []string `json:"schemas"`
+}
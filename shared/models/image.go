@@ -1,11 +1,15 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/amandeep2102/image-processor/shared/metadata"
+)
 
 type Image struct {
 	ID           string    `json:"id"`
 	Filename     string    `json:"filename"`
-	OriginalPath string    `json:"original_path"`
+	OriginalPath string    `json:"-"` // storage key
 	ContentType  string    `json:"content_type"`
 	SizeBytes    int64     `json:"size_bytes"`
 	Width        int       `json:"width"`
@@ -13,27 +17,54 @@ type Image struct {
 	UploadedBy   string    `json:"uploaded_by"`
 	UploadedAt   time.Time `json:"uploaded_at"`
 	Status       string    `json:"status"`
+	ContentHash  string    `json:"content_hash"`
 }
 
 type ProcessedImage struct {
 	ID               string                 `json:"id"`
 	OriginalImageID  string                 `json:"original_image_id"`
 	OperationType    string                 `json:"operation_type"`
-	ProcessedPath    string                 `json:"processed_path"`
+	ProcessedPath    string                 `json:"-"` // storage key
+	ContentType      string                 `json:"content_type"`
+	GroupID          string                 `json:"group_id,omitempty"`
+	ContentHash      string                 `json:"content_hash"`
 	Parameters       map[string]interface{} `json:"parameters"`
 	ProcessingTimeMs int                    `json:"processing_time_ms"`
 	CreatedAt        time.Time              `json:"created_at"`
 }
 
-type ProcessRequest struct {
-	ImageID    string                 `json:"image_id"`
-	Operation  string                 `json:"operation"`
-	Parameters map[string]interface{} `json:"parameters"`
+// UploadResponse is returned by POST /upload. Duplicate is set when the same
+// content was uploaded before and its stored file is shared.
+type UploadResponse struct {
+	ID           string          `json:"id"`
+	Filename     string          `json:"filename"`
+	Size         int64           `json:"size"`
+	ContentType  string          `json:"content_type"`
+	Width        int             `json:"width"`
+	Height       int             `json:"height"`
+	UploadedBy   string          `json:"uploaded_by"`
+	UploadTimeMs int64           `json:"upload_time_ms"`
+	Metadata     metadata.Report `json:"metadata"`
+	ContentHash  string          `json:"content_hash"`
+	Duplicate    bool            `json:"duplicate"`
+}
+
+type ImageListResponse struct {
+	Images []Image `json:"images"`
+	Count  int     `json:"count"`
+}
+
+type OperationStats struct {
+	Operation string  `json:"operation"`
+	Count     int     `json:"count"`
+	AvgTimeMs float64 `json:"avg_time_ms"`
+	MinTimeMs float64 `json:"min_time_ms"`
+	MaxTimeMs float64 `json:"max_time_ms"`
 }
 
-type ProcessResponse struct {
-	Success          bool   `json:"success"`
-	ProcessedID      string `json:"processed_id,omitempty"`
-	Message          string `json:"message,omitempty"`
-	ProcessingTimeMs int    `json:"processing_time_ms,omitempty"`
+type StatsResponse struct {
+	TotalImages    int              `json:"total_images"`
+	TotalProcessed int              `json:"total_processed"`
+	TotalSizeBytes int64            `json:"total_size_bytes"`
+	Operations     []OperationStats `json:"operations"`
 }
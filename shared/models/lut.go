@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// LUT is an uploaded .cube file for the lut operation.
+type LUT struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Title      string    `json:"title"`
+	Dimensions int       `json:"dimensions"`
+	Size       int       `json:"size"`
+	UploadedBy string    `json:"uploaded_by"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type LUTListResponse struct {
+	LUTs  []LUT `json:"luts"`
+	Count int   `json:"count"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// PresetStep is one operation of a preset pipeline, with the same
+// parameters as the corresponding /process endpoint.
+type PresetStep struct {
+	Operation  string                 `json:"operation"`
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+type Preset struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Version     int          `json:"version"`
+	Description string       `json:"description"`
+	Steps       []PresetStep `json:"steps"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type CreatePresetRequest struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Steps       []PresetStep `json:"steps"`
+}
+
+// UpdatePresetRequest adds a new version of a preset.
+type UpdatePresetRequest struct {
+	Description string       `json:"description,omitempty"`
+	Steps       []PresetStep `json:"steps"`
+}
+
+type PresetVersion struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PresetResponse struct {
+	Preset   Preset          `json:"preset"`
+	Versions []PresetVersion `json:"versions"`
+}
+
+type PresetListResponse struct {
+	Presets []Preset `json:"presets"`
+	Count   int      `json:"count"`
+}
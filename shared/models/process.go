@@ -0,0 +1,221 @@
+package models
+
+// Requests to the /process endpoints. Fields left out take the operation's
+// defaults; pointers distinguish an explicit zero from a missing value.
+
+// OutputOptions are accepted by every operation that writes a new image.
+type OutputOptions struct {
+	OutputFormat  string `json:"output_format,omitempty"`
+	Quality       int    `json:"quality,omitempty"`
+	StripMetadata *bool  `json:"strip_metadata,omitempty"`
+}
+
+// FrameOptions select what is written for animated GIFs by the operations
+// that process them frame by frame.
+type FrameOptions struct {
+	Frame         *int `json:"frame,omitempty"`
+	Sprite        bool `json:"sprite,omitempty"`
+	SpriteColumns int  `json:"sprite_columns,omitempty"`
+}
+
+type ResizeRequest struct {
+	OutputOptions
+	FrameOptions
+	ImageID      string `json:"image_id" binding:"required"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Mode         string `json:"mode,omitempty"`
+	AllowUpscale *bool  `json:"allow_upscale,omitempty"`
+	Background   string `json:"background,omitempty"`
+	Position     string `json:"position,omitempty"`
+	Resample     string `json:"resample,omitempty"`
+}
+
+type CropRequest struct {
+	OutputOptions
+	FrameOptions
+	ImageID string `json:"image_id" binding:"required"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+type ThumbnailRequest struct {
+	OutputOptions
+	FrameOptions
+	ImageID  string `json:"image_id" binding:"required"`
+	Size     int    `json:"size"`
+	Resample string `json:"resample,omitempty"`
+}
+
+type FilterRequest struct {
+	OutputOptions
+	FrameOptions
+	ImageID    string   `json:"image_id" binding:"required"`
+	FilterType string   `json:"filter_type"`
+	Intensity  *float64 `json:"intensity,omitempty"`
+	Levels     *int     `json:"levels,omitempty"`
+
+	// Only used by the convolve filter
+	Kernel    [][]float64 `json:"kernel,omitempty"`
+	Normalize bool        `json:"normalize,omitempty"`
+	Abs       bool        `json:"abs,omitempty"`
+	Bias      int         `json:"bias,omitempty"`
+}
+
+type ConvertRequest struct {
+	ImageID       string `json:"image_id" binding:"required"`
+	Format        string `json:"format"`
+	Quality       int    `json:"quality,omitempty"`
+	Compression   string `json:"compression,omitempty"`
+	Predictor     *bool  `json:"predictor,omitempty"`
+	Colors        *int   `json:"colors,omitempty"`
+	Dither        *bool  `json:"dither,omitempty"`
+	StripMetadata *bool  `json:"strip_metadata,omitempty"`
+}
+
+type WatermarkRequest struct {
+	OutputOptions
+	ImageID  string   `json:"image_id" binding:"required"`
+	LogoID   string   `json:"logo_id"`
+	Position string   `json:"position,omitempty"`
+	Margin   *int     `json:"margin,omitempty"`
+	Scale    *float64 `json:"scale,omitempty"`
+	Opacity  *float64 `json:"opacity,omitempty"`
+	Tiled    bool     `json:"tiled,omitempty"`
+}
+
+type TextRequest struct {
+	OutputOptions
+	ImageID      string   `json:"image_id" binding:"required"`
+	Text         string   `json:"text"`
+	Font         string   `json:"font,omitempty"`
+	FontSize     *float64 `json:"font_size,omitempty"`
+	Color        string   `json:"color,omitempty"`
+	StrokeColor  string   `json:"stroke_color,omitempty"`
+	StrokeWidth  int      `json:"stroke_width,omitempty"`
+	ShadowColor  string   `json:"shadow_color,omitempty"`
+	ShadowOffset int      `json:"shadow_offset,omitempty"`
+	Align        string   `json:"align,omitempty"`
+	Position     string   `json:"position,omitempty"`
+	Margin       *int     `json:"margin,omitempty"`
+	BoxWidth     *int     `json:"box_width,omitempty"`
+}
+
+type AdjustRequest struct {
+	OutputOptions
+	ImageID    string   `json:"image_id" binding:"required"`
+	Brightness float64  `json:"brightness,omitempty"`
+	Contrast   float64  `json:"contrast,omitempty"`
+	Gamma      *float64 `json:"gamma,omitempty"`
+	Saturation float64  `json:"saturation,omitempty"`
+	Hue        float64  `json:"hue,omitempty"`
+}
+
+type LUTRequest struct {
+	OutputOptions
+	ImageID  string   `json:"image_id" binding:"required"`
+	LUT      string   `json:"lut"`
+	Strength *float64 `json:"strength,omitempty"`
+}
+
+type AutoEnhanceRequest struct {
+	OutputOptions
+	ImageID      string   `json:"image_id" binding:"required"`
+	WhiteBalance *bool    `json:"white_balance,omitempty"`
+	Levels       *bool    `json:"levels,omitempty"`
+	Contrast     *bool    `json:"contrast,omitempty"`
+	Clip         *float64 `json:"clip,omitempty"`
+}
+
+// Region is an area to redact: either a rectangle or, when Points is set, a
+// polygon in image pixel coordinates.
+type Region struct {
+	X      int      `json:"x"`
+	Y      int      `json:"y"`
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Points [][2]int `json:"points,omitempty"`
+}
+
+// RedactRequest takes no strip_metadata option: redacted images are always
+// stripped.
+type RedactRequest struct {
+	OutputFormat string `json:"output_format,omitempty"`
+	Quality      int    `json:"quality,omitempty"`
+
+	ImageID   string   `json:"image_id" binding:"required"`
+	Regions   []Region `json:"regions"`
+	Mode      string   `json:"mode,omitempty"`
+	BlockSize *int     `json:"block_size,omitempty"`
+	Sigma     *float64 `json:"sigma,omitempty"`
+	Color     string   `json:"color,omitempty"`
+}
+
+type ResponsiveRequest struct {
+	OutputOptions
+	ImageID      string   `json:"image_id" binding:"required"`
+	Widths       []int    `json:"widths,omitempty"`
+	Formats      []string `json:"formats,omitempty"`
+	AllowUpscale *bool    `json:"allow_upscale,omitempty"`
+	Resample     string   `json:"resample,omitempty"`
+}
+
+// RunPresetRequest runs a stored preset, by default its latest version.
+type RunPresetRequest struct {
+	ImageID string `json:"image_id" binding:"required"`
+	Version int    `json:"version,omitempty"`
+}
+
+// JobResponse is returned when a job is queued. Poll GET /job/:job_id for
+// its JobResult.
+type JobResponse struct {
+	JobID     string `json:"job_id"`
+	GroupID   string `json:"group_id,omitempty"` // responsive variants
+	Preset    string `json:"preset,omitempty"`
+	Version   int    `json:"version,omitempty"`
+	Message   string `json:"message"`
+	QueueSize int    `json:"queue_size,omitempty"`
+}
+
+type JobResult struct {
+	JobID            string `json:"job_id"`
+	Success          bool   `json:"success"`
+	ProcessedID      string `json:"processed_id,omitempty"`
+	ContentHash      string `json:"content_hash,omitempty"`
+	Message          string `json:"message,omitempty"`
+	ProcessingTimeMs int64  `json:"processing_time_ms"`
+	WorkerID         int    `json:"worker_id"` // Track which worker processed it
+
+	// Values computed by the operation, e.g. auto_enhance corrections
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// JobStatusResponse is returned while a job has no result yet.
+type JobStatusResponse struct {
+	JobID   string `json:"job_id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// TransformRequest runs a URL transformation synchronously.
+type TransformRequest struct {
+	ImageID   string `json:"image_id" binding:"required"`
+	Transform string `json:"transform"`
+
+	// Transform a processed image instead of the original
+	SourceProcessedID string `json:"source_processed_id,omitempty"`
+}
+
+type TransformResponse struct {
+	ProcessedID string `json:"processed_id"`
+	ContentHash string `json:"content_hash"`
+	Transform   string `json:"transform"`
+}
+
+type WorkerStatsResponse struct {
+	QueueSize     int     `json:"queue_size"`
+	QueueCapacity int     `json:"queue_capacity"`
+	QueueUsage    float64 `json:"queue_usage"` // percent
+}
@@ -0,0 +1,42 @@
+package models
+
+// Types lists the request and response models by name, for generating the
+// JSON Schemas served to clients.
+var Types = map[string]interface{}{
+	"AdjustRequest":         AdjustRequest{},
+	"AutoEnhanceRequest":    AutoEnhanceRequest{},
+	"BackendHealthResponse": BackendHealthResponse{},
+	"ConvertRequest":        ConvertRequest{},
+	"CreatePresetRequest":   CreatePresetRequest{},
+	"CropRequest":           CropRequest{},
+	"ErrorResponse":         ErrorResponse{},
+	"FilterRequest":         FilterRequest{},
+	"HealthResponse":        HealthResponse{},
+	"ImageListResponse":     ImageListResponse{},
+	"JobResponse":           JobResponse{},
+	"JobResult":             JobResult{},
+	"JobStatusResponse":     JobStatusResponse{},
+	"LUT":                   LUT{},
+	"LUTListResponse":       LUTListResponse{},
+	"LUTRequest":            LUTRequest{},
+	"MessageResponse":       MessageResponse{},
+	"Preset":                Preset{},
+	"PresetListResponse":    PresetListResponse{},
+	"PresetResponse":        PresetResponse{},
+	"RedactRequest":         RedactRequest{},
+	"ResizeRequest":         ResizeRequest{},
+	"ResponsiveRequest":     ResponsiveRequest{},
+	"RunPresetRequest":      RunPresetRequest{},
+	"SchemaListResponse":    SchemaListResponse{},
+	"SignRequest":           SignRequest{},
+	"SignResponse":          SignResponse{},
+	"StatsResponse":         StatsResponse{},
+	"TextRequest":           TextRequest{},
+	"ThumbnailRequest":      ThumbnailRequest{},
+	"TransformRequest":      TransformRequest{},
+	"TransformResponse":     TransformResponse{},
+	"UpdatePresetRequest":   UpdatePresetRequest{},
+	"UploadResponse":        UploadResponse{},
+	"WatermarkRequest":      WatermarkRequest{},
+	"WorkerStatsResponse":   WorkerStatsResponse{},
+}
@@ -0,0 +1,60 @@
+package preset
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+)
+
+const maxSteps = 10
+
+// Operations that can be used as preset steps. responsive writes several
+// images, so it cannot feed a following step and is not included.
+var Operations = map[string]bool{
+	"resize":       true,
+	"thumbnail":    true,
+	"crop":         true,
+	"filter":       true,
+	"convert":      true,
+	"watermark":    true,
+	"text":         true,
+	"adjust":       true,
+	"lut":          true,
+	"auto_enhance": true,
+	"redact":       true,
+}
+
+// Parameters the worker sets on each step. A preset cannot supply them.
+var reservedParameters = []string{"job_id", "source_path"}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("name must be 1-100 letters, digits, '-' or '_'")
+	}
+	return nil
+}
+
+// Validate checks the shape of a pipeline. Operation parameters are
+// validated by the processors when the preset runs.
+func Validate(steps []models.PresetStep) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("a preset needs at least one step")
+	}
+	if len(steps) > maxSteps {
+		return fmt.Errorf("a preset can have at most %d steps", maxSteps)
+	}
+	for i, step := range steps {
+		if !Operations[step.Operation] {
+			return fmt.Errorf("step %d: unsupported operation: %s", i+1, step.Operation)
+		}
+		for _, key := range reservedParameters {
+			if _, ok := step.Parameters[key]; ok {
+				return fmt.Errorf("step %d: %s cannot be set", i+1, key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package signing
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	key := []byte("secret")
+	path := "/image/abc/processed/def"
+	expires := int64(1700000000)
+	signature := Signature(key, path, expires)
+
+	if Signature(key, path, expires) != signature {
+		t.Fatal("signature is not deterministic")
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		path    string
+		expires int64
+	}{
+		{"wrong key", []byte("other"), path, expires},
+		{"tampered path", key, "/image/abc/processed/xyz", expires},
+		{"path prefix", key, "/image/abc", expires},
+		{"extended expiry", key, path, expires + 1},
+	}
+	for _, tt := range tests {
+		if Signature(tt.key, tt.path, tt.expires) == signature {
+			t.Errorf("%s: signature matches the original", tt.name)
+		}
+	}
+}
+
+func TestURL(t *testing.T) {
+	key := []byte("secret")
+	path := "/i/abc/w_400%2Ch_300.png"
+
+	before := time.Now()
+	signed, expiresAt := URL(key, path, time.Hour)
+
+	if expiresAt.Before(before.Add(time.Hour-time.Second)) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expires at %v, want an hour from now", expiresAt)
+	}
+
+	rawPath, rawQuery, ok := strings.Cut(signed, "?")
+	if !ok || rawPath != path {
+		t.Fatalf("URL(%q) = %q, want the path followed by a query", path, signed)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || expires != expiresAt.Unix() {
+		t.Errorf("expires = %q, want %d", query.Get("expires"), expiresAt.Unix())
+	}
+	if query.Get("signature") != Signature(key, path, expires) {
+		t.Errorf("signature %q does not verify", query.Get("signature"))
+	}
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotExist is returned when a key is not stored.
+var ErrNotExist = errors.New("blob does not exist")
+
+// Blob stores files by key. Keys are slash separated paths relative to the
+// root of the storage, e.g. "ab/ab12....png".
+type Blob interface {
+	Put(key string, r io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+	Stat(key string) (Info, error)
+	List(prefix string) ([]Info, error)
+}
+
+type Info struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// localPather is implemented by drivers whose blobs are plain files, so
+// callers that need a path (image decoders, processors) can skip copying.
+type localPather interface {
+	LocalPath(key string) (string, error)
+}
+
+// Open returns the driver for a storage URL:
+//
+//	file:///var/lib/images            local directory (a plain path works too)
+//	s3://bucket/prefix?endpoint=host:9000&secure=false
+//
+// The frontend, backend and cleanup tool must all open the same storage.
+// The keys are only used by S3.
+func Open(rawURL, accessKey, secretKey string) (Blob, error) {
+	if !strings.Contains(rawURL, "://") {
+		return NewLocal(rawURL), nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage URL: %v", err)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return NewLocal(u.Path), nil
+	case "s3":
+		endpoint := u.Query().Get("endpoint")
+		if endpoint == "" {
+			endpoint = "s3.amazonaws.com"
+		}
+		return NewS3(S3Config{
+			Endpoint:  endpoint,
+			Bucket:    u.Host,
+			Prefix:    strings.Trim(u.Path, "/"),
+			Region:    u.Query().Get("region"),
+			Secure:    u.Query().Get("secure") != "false",
+			AccessKey: accessKey,
+			SecretKey: secretKey,
+		})
+	default:
+		return nil, fmt.Errorf("unsupported storage scheme: %s (supported: file, s3)", u.Scheme)
+	}
+}
+
+// MaxCacheBytes bounds the download cache of LocalFile. Once a download
+// pushes the cache past it, the least recently used copies are removed.
+var MaxCacheBytes int64 = 2 << 30
+
+// LocalFile returns a local path holding the content of key, for code that
+// needs a real file. Local blobs are used in place; others are downloaded
+// into cacheDir and reused while the cached copy is not older than the blob.
+func LocalFile(b Blob, key, cacheDir string) (string, error) {
+	if l, ok := b.(localPather); ok {
+		return l.LocalPath(key)
+	}
+
+	clean := path.Clean("/" + key)[1:]
+	if clean == "" {
+		return "", fmt.Errorf("invalid key: %q", key)
+	}
+	cached := filepath.Join(cacheDir, filepath.FromSlash(clean))
+
+	info, err := b.Stat(key)
+	if err != nil {
+		return "", err
+	}
+	if fi, err := os.Stat(cached); err == nil && fi.Size() == info.Size && !fi.ModTime().Before(info.ModTime) {
+		// The modification time doubles as the last use for eviction
+		now := time.Now()
+		os.Chtimes(cached, now, now)
+		return cached, nil
+	}
+
+	r, err := b.Get(key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	cache := NewLocal(cacheDir)
+	if err := cache.Put(clean, r, info.Size, ""); err != nil {
+		return "", fmt.Errorf("failed to cache blob: %v", err)
+	}
+	// Eviction is best effort: concurrent downloads prune the same directory
+	pruneCache(cache, MaxCacheBytes, clean)
+	return cached, nil
+}
+
+// pruneCache deletes the least recently used files until the cache holds at
+// most limit bytes. The file just downloaded, keep, is never deleted.
+func pruneCache(cache *Local, limit int64, keep string) error {
+	entries, err := cache.List("")
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.Size
+	}
+	if total <= limit {
+		return nil
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime.Before(entries[j].ModTime)
+	})
+	for _, e := range entries {
+		if total <= limit {
+			break
+		}
+		if e.Key == keep {
+			continue
+		}
+		if err := cache.Delete(e.Key); err != nil {
+			return err
+		}
+		total -= e.Size
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalRejectsUncleanKeys(t *testing.T) {
+	l := NewLocal(t.TempDir())
+
+	keys := []string{
+		"",
+		"../outside",
+		"a/../../outside",
+		"a/../b",
+		"./a",
+		"a//b",
+		"a/",
+	}
+	for _, key := range keys {
+		if _, err := l.LocalPath(key); err == nil {
+			t.Errorf("LocalPath(%q) accepted an unclean key", key)
+		}
+		if err := l.Put(key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) accepted an unclean key", key)
+		}
+		if _, err := l.Get(key); err == nil {
+			t.Errorf("Get(%q) accepted an unclean key", key)
+		}
+	}
+}
+
+func TestLocalLegacyAbsoluteKeys(t *testing.T) {
+	l := NewLocal(t.TempDir())
+	legacy := filepath.Join(t.TempDir(), "upload.png")
+
+	got, err := l.LocalPath(legacy)
+	if err != nil || got != legacy {
+		t.Errorf("LocalPath(%q) = %q, %v; want the path unchanged", legacy, got, err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+
+	blobs := map[string]string{
+		"ab/abcdef.png":  "png data",
+		"ab/abcd00.jpg":  "jpeg data",
+		"luts/warm.cube": "LUT_3D_SIZE 2",
+	}
+	for key, data := range blobs {
+		if err := l.Put(key, strings.NewReader(data), int64(len(data)), ""); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{"all", "", []string{"ab/abcd00.jpg", "ab/abcdef.png", "luts/warm.cube"}},
+		{"directory", "ab/", []string{"ab/abcd00.jpg", "ab/abcdef.png"}},
+		{"partial name", "luts/w", []string{"luts/warm.cube"}},
+		{"none", "zz/", nil},
+	}
+	for _, tt := range tests {
+		t.Run("List "+tt.name, func(t *testing.T) {
+			infos, err := l.List(tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, info := range infos {
+				keys = append(keys, info.Key)
+				if info.Size != int64(len(blobs[info.Key])) {
+					t.Errorf("%s: size %d, want %d", info.Key, info.Size, len(blobs[info.Key]))
+				}
+			}
+			slices.Sort(keys)
+			if !slices.Equal(keys, tt.want) {
+				t.Errorf("List(%q) = %v, want %v", tt.prefix, keys, tt.want)
+			}
+		})
+	}
+
+	for key, data := range blobs {
+		info, err := l.Stat(key)
+		if err != nil || info.Key != key || info.Size != int64(len(data)) {
+			t.Errorf("Stat(%q) = %+v, %v", key, info, err)
+		}
+
+		r, err := l.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil || string(got) != data {
+			t.Errorf("Get(%q) = %q, %v; want %q", key, got, err, data)
+		}
+	}
+
+	// Overwriting replaces the content
+	if err := l.Put("ab/abcdef.png", strings.NewReader("new"), 3, ""); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := l.Stat("ab/abcdef.png"); info.Size != 3 {
+		t.Errorf("size after overwrite %d, want 3", info.Size)
+	}
+
+	if err := l.Delete("ab/abcdef.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Stat("ab/abcdef.png"); err != ErrNotExist {
+		t.Errorf("Stat after Delete: %v, want ErrNotExist", err)
+	}
+	if _, err := l.Get("ab/abcdef.png"); err != ErrNotExist {
+		t.Errorf("Get after Delete: %v, want ErrNotExist", err)
+	}
+	if err := l.Delete("ab/abcdef.png"); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+}
+
+func TestLocalListSkipsPartialWrites(t *testing.T) {
+	root := t.TempDir()
+	l := NewLocal(root)
+	if err := os.WriteFile(filepath.Join(root, ".blob-123"), []byte("partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := l.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 {
+		t.Errorf("List returned temporary files: %+v", infos)
+	}
+}
+
+func TestListMissingRoot(t *testing.T) {
+	l := NewLocal(filepath.Join(t.TempDir(), "missing"))
+	infos, err := l.List("")
+	if err != nil || len(infos) != 0 {
+		t.Errorf("List on a missing root = %v, %v; want nothing", infos, err)
+	}
+}
+
+// remote hides the local driver's paths so LocalFile has to download.
+type remote struct{ Blob }
+
+func TestLocalFile(t *testing.T) {
+	local := NewLocal(t.TempDir())
+	if err := local.Put("ab/ab01.png", strings.NewReader("pixels"), 6, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := LocalFile(local, "ab/ab01.png", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := local.LocalPath("ab/ab01.png"); path != want {
+		t.Errorf("local blob was copied to %s", path)
+	}
+
+	cacheDir := t.TempDir()
+	path, err = LocalFile(remote{local}, "ab/ab01.png", cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, cacheDir) {
+		t.Errorf("remote blob served from %s, outside the cache", path)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "pixels" {
+		t.Errorf("cached copy holds %q", data)
+	}
+
+	// An updated blob replaces the stale copy
+	if err := local.Put("ab/ab01.png", strings.NewReader("new pixels"), 10, ""); err != nil {
+		t.Fatal(err)
+	}
+	path, err = LocalFile(remote{local}, "ab/ab01.png", cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new pixels" {
+		t.Errorf("cached copy holds %q after the blob changed", data)
+	}
+
+	if _, err := LocalFile(remote{local}, "ab/missing.png", cacheDir); err != ErrNotExist {
+		t.Errorf("missing blob: %v, want ErrNotExist", err)
+	}
+}
+
+func TestLocalFileEvictsLeastRecentlyUsed(t *testing.T) {
+	defer func(limit int64) { MaxCacheBytes = limit }(MaxCacheBytes)
+	MaxCacheBytes = 25
+
+	local := NewLocal(t.TempDir())
+	for _, key := range []string{"a", "b", "c"} {
+		if err := local.Put(key, bytes.NewReader(make([]byte, 10)), 10, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cacheDir := t.TempDir()
+	for _, key := range []string{"a", "b"} {
+		if _, err := LocalFile(remote{local}, key, cacheDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// b was used longer ago than a
+	hourAgo := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(cacheDir, "b"), hourAgo, hourAgo)
+
+	if _, err := LocalFile(remote{local}, "c", cacheDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		_, err := os.Stat(filepath.Join(cacheDir, key))
+		if cached := err == nil; cached != want {
+			t.Errorf("%s cached = %v, want %v", key, cached, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint  string // host[:port], e.g. "localhost:9000" for MinIO
+	Bucket    string
+	Prefix    string // optional key prefix inside the bucket
+	Region    string
+	Secure    bool
+	AccessKey string
+	SecretKey string
+}
+
+// S3 stores blobs as objects in an S3-compatible bucket.
+type S3 struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+func NewS3(cfg S3Config) (*S3, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.Secure,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{client: client, bucket: cfg.Bucket, prefix: cfg.Prefix}, nil
+}
+
+func (s *S3) object(key string) string {
+	return path.Join(s.prefix, key)
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(context.Background(), s.bucket, s.object(key), r, size,
+		minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3) Get(key string) (io.ReadSeekCloser, error) {
+	// Stat first: GetObject is lazy and only fails on the first read
+	if _, err := s.Stat(key); err != nil {
+		return nil, err
+	}
+	return s.client.GetObject(context.Background(), s.bucket, s.object(key), minio.GetObjectOptions{})
+}
+
+func (s *S3) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, s.object(key), minio.RemoveObjectOptions{})
+}
+
+func (s *S3) Stat(key string) (Info, error) {
+	obj, err := s.client.StatObject(context.Background(), s.bucket, s.object(key), minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return Info{}, ErrNotExist
+		}
+		return Info{}, err
+	}
+	return Info{Key: key, Size: obj.Size, ModTime: obj.LastModified}, nil
+}
+
+func (s *S3) List(prefix string) ([]Info, error) {
+	var infos []Info
+	objects := s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{
+		Prefix:    s.object(prefix),
+		Recursive: true,
+	})
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		key := strings.TrimPrefix(strings.TrimPrefix(obj.Key, s.prefix), "/")
+		infos = append(infos, Info{Key: key, Size: obj.Size, ModTime: obj.LastModified})
+	}
+	return infos, nil
+}
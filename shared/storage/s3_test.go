@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a minimal path-style S3 endpoint holding a single bucket, enough
+// for the requests the S3 driver makes.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	modTime time.Time
+}
+
+func newFakeS3(t *testing.T, bucket string) (*fakeS3, *httptest.Server) {
+	f := &fakeS3{bucket: bucket, objects: map[string][]byte{}, modTime: time.Now().UTC().Truncate(time.Second)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != f.bucket {
+		f.error(w, 404, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == "GET" && r.URL.Query().Has("location"):
+		fmt.Fprint(w, `<LocationConstraint>us-east-1</LocationConstraint>`)
+
+	case key == "" && r.Method == "GET":
+		f.list(w, r.URL.Query().Get("prefix"))
+
+	case r.Method == "PUT":
+		data, err := readPayload(r)
+		if err != nil {
+			f.error(w, 400, "IncompleteBody")
+			return
+		}
+		f.objects[key] = data
+		w.Header().Set("ETag", `"etag"`)
+
+	case r.Method == "HEAD" || r.Method == "GET":
+		data, ok := f.objects[key]
+		if !ok {
+			f.error(w, 404, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		http.ServeContent(w, r, key, f.modTime, bytes.NewReader(data))
+
+	case r.Method == "DELETE":
+		delete(f.objects, key)
+		w.WriteHeader(204)
+
+	default:
+		f.error(w, 405, "MethodNotAllowed")
+	}
+}
+
+func (f *fakeS3) error(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix string) {
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+		ETag         string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		KeyCount    int
+		IsTruncated bool
+		Contents    []content
+	}{Name: f.bucket, Prefix: prefix}
+
+	for key, data := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			result.Contents = append(result.Contents, content{key, len(data), f.modTime.Format(time.RFC3339), `"etag"`})
+		}
+	}
+	result.KeyCount = len(result.Contents)
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// readPayload decodes the aws-chunked bodies minio-go sends over plain HTTP.
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data []byte
+	br := bufio.NewReader(r.Body)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, size+2) // data and CRLF
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:size]...)
+	}
+}
+
+func newTestS3(t *testing.T, prefix string) (*fakeS3, *S3) {
+	fake, srv := newFakeS3(t, "images")
+	u, _ := url.Parse(srv.URL)
+
+	s, err := NewS3(S3Config{
+		Endpoint:  u.Host,
+		Bucket:    "images",
+		Prefix:    prefix,
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fake, s
+}
+
+func TestS3(t *testing.T) {
+	fake, s := newTestS3(t, "store")
+
+	blobs := map[string]string{
+		"ab/abcdef.png":  "png data",
+		"ab/abcd00.jpg":  "jpeg data",
+		"luts/warm.cube": "LUT_3D_SIZE 2",
+	}
+	for key, data := range blobs {
+		if err := s.Put(key, strings.NewReader(data), int64(len(data)), "image/png"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+	}
+	if _, ok := fake.objects["store/ab/abcdef.png"]; !ok {
+		t.Errorf("objects are not stored under the prefix: %v", fake.objects)
+	}
+
+	for key, data := range blobs {
+		info, err := s.Stat(key)
+		if err != nil || info.Key != key || info.Size != int64(len(data)) {
+			t.Errorf("Stat(%q) = %+v, %v", key, info, err)
+		}
+
+		r, err := s.Get(key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != data {
+			t.Errorf("Get(%q) = %q, %v; want %q", key, got, err, data)
+		}
+
+		// Range requests back Seek, which serveFile relies on
+		if _, err := r.Seek(4, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		rest, err := io.ReadAll(r)
+		if err != nil || string(rest) != data[4:] {
+			t.Errorf("Get(%q) after Seek = %q, %v; want %q", key, rest, err, data[4:])
+		}
+		r.Close()
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"", []string{"ab/abcd00.jpg", "ab/abcdef.png", "luts/warm.cube"}},
+		{"ab/", []string{"ab/abcd00.jpg", "ab/abcdef.png"}},
+		{"zz/", nil},
+	}
+	for _, tt := range tests {
+		infos, err := s.List(tt.prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, info := range infos {
+			keys = append(keys, info.Key)
+		}
+		slices.Sort(keys)
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, keys, tt.want)
+		}
+	}
+
+	if err := s.Delete("ab/abcdef.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Stat("ab/abcdef.png"); err != ErrNotExist {
+		t.Errorf("Stat after Delete: %v, want ErrNotExist", err)
+	}
+	if _, err := s.Get("ab/abcdef.png"); err != ErrNotExist {
+		t.Errorf("Get after Delete: %v, want ErrNotExist", err)
+	}
+}
+
+func TestS3LocalFile(t *testing.T) {
+	_, s := newTestS3(t, "")
+	if err := s.Put("ab/ab01.png", strings.NewReader("pixels"), 6, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		path, err := LocalFile(s, "ab/ab01.png", cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(path, cacheDir) {
+			t.Errorf("S3 blob served from %s, outside the cache", path)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	_, srv := newFakeS3(t, "images")
+	u, _ := url.Parse(srv.URL)
+
+	tests := []struct {
+		url     string
+		wantErr bool
+		local   bool
+	}{
+		{url: t.TempDir(), local: true},
+		{url: "file://" + t.TempDir(), local: true},
+		{url: "s3://images/prefix?endpoint=" + u.Host + "&secure=false&region=us-east-1"},
+		{url: "ftp://host/dir", wantErr: true},
+	}
+	for _, tt := range tests {
+		b, err := Open(tt.url, "access", "secret")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Open(%q) error = %v, want error %v", tt.url, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if _, ok := b.(*Local); ok != tt.local {
+			t.Errorf("Open(%q) returned %T", tt.url, b)
+		}
+	}
+}
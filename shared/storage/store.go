@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// Store keeps files content-addressed by SHA-256, so identical content is
+// written once and shared. The blobs table counts the images and processed
+// images referencing each blob; the blob is deleted with the last reference.
+type Store struct {
+	db   *sql.DB
+	blob Blob
+}
+
+// Ref is a reference to a stored blob.
+type Ref struct {
+	Hash      string
+	Key       string
+	Duplicate bool // the content was already stored
+}
+
+func NewStore(db *sql.DB, blob Blob) *Store {
+	return &Store{db: db, blob: blob}
+}
+
+// Put stores data, adding a reference to its blob. ext (e.g. ".png") names
+// the blob when the content is new. It also sets the blob's referenced_at,
+// which keeps the database refresh from removing the blob before the caller
+// has recorded its reference.
+func (s *Store) Put(data []byte, ext string) (Ref, error) {
+	sum := sha256.Sum256(data)
+	ref := Ref{Hash: hex.EncodeToString(sum[:])}
+	key := ref.Hash[:2] + "/" + ref.Hash + ext
+
+	// xmax is zero only for a freshly inserted row
+	var inserted bool
+	err := s.db.QueryRow(`
+        INSERT INTO blobs (hash, path, size_bytes, ref_count)
+        VALUES ($1, $2, $3, 1)
+        ON CONFLICT (hash) DO UPDATE SET ref_count = blobs.ref_count + 1, referenced_at = CURRENT_TIMESTAMP
+        RETURNING path, xmax = 0
+    `, ref.Hash, key, len(data)).Scan(&ref.Key, &inserted)
+	if err != nil {
+		return ref, fmt.Errorf("failed to record blob: %v", err)
+	}
+	ref.Duplicate = !inserted
+
+	// Write the blob unless it is already there; a concurrent Put of the
+	// same content writes identical bytes
+	if _, err := s.blob.Stat(ref.Key); err == nil {
+		return ref, nil
+	}
+	err = s.blob.Put(ref.Key, bytes.NewReader(data), int64(len(data)), mime.TypeByExtension(filepath.Ext(ref.Key)))
+	if err != nil {
+		s.Release(ref.Hash)
+		return ref, fmt.Errorf("failed to write blob: %v", err)
+	}
+	return ref, nil
+}
+
+// PutFile moves a local file into the store.
+func (s *Store) PutFile(path string) (Ref, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Ref{}, err
+	}
+
+	ref, err := s.Put(data, filepath.Ext(path))
+	if err != nil {
+		return ref, err
+	}
+	os.Remove(path)
+	return ref, nil
+}
+
+// Release drops a reference to a blob, deleting it when none remain.
+func (s *Store) Release(hash string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	var key string
+	err = tx.QueryRow(`
+        UPDATE blobs SET ref_count = ref_count - 1 WHERE hash = $1
+        RETURNING ref_count, path
+    `, hash).Scan(&count, &key)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if count <= 0 {
+		if _, err := tx.Exec("DELETE FROM blobs WHERE hash = $1", hash); err != nil {
+			return err
+		}
+		// Delete the blob before committing: a concurrent Put of the same
+		// content waits on the row lock and then writes the blob again
+		if err := s.blob.Delete(key); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
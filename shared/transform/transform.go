@@ -0,0 +1,187 @@
+package transform
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/amandeep2102/image-processor/shared/models"
+)
+
+// A transformation string is a comma separated list of key_value options
+// with an optional output extension, e.g. "w_400,h_300,fit_fill,q_80.webp":
+//
+//	w, h  target width and height (at least one resizes the image)
+//	fit   resize mode: fit, fill, exact or pad
+//	q     quality, 1-100
+//	bg    pad background color as hex, e.g. bg_ffffff
+//	g     gravity for fill and pad, e.g. g_top_left
+//
+// Without an extension the source format is kept.
+
+const maxDimension = 4096
+
+var formats = map[string]string{
+	"jpg":  "jpeg",
+	"jpeg": "jpeg",
+	"png":  "png",
+	"gif":  "gif",
+	"tif":  "tiff",
+	"tiff": "tiff",
+	"bmp":  "bmp",
+	"webp": "webp",
+}
+
+var fits = map[string]bool{"fit": true, "fill": true, "exact": true, "pad": true}
+
+var gravities = map[string]bool{
+	"center": true, "top_left": true, "top": true, "top_right": true, "left": true,
+	"right": true, "bottom_left": true, "bottom": true, "bottom_right": true,
+}
+
+type Options struct {
+	Width      int
+	Height     int
+	Fit        string
+	Quality    int
+	Background string
+	Gravity    string
+	Format     string
+}
+
+func Parse(s string) (Options, error) {
+	var o Options
+
+	if ext := path.Ext(s); ext != "" {
+		format, ok := formats[strings.ToLower(ext[1:])]
+		if !ok {
+			return o, fmt.Errorf("unsupported format: %s", ext[1:])
+		}
+		o.Format = format
+		s = strings.TrimSuffix(s, ext)
+	}
+
+	for _, option := range strings.Split(s, ",") {
+		if option == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(option, "_")
+		if !ok || value == "" {
+			return o, fmt.Errorf("invalid option: %s", option)
+		}
+
+		var err error
+		switch key {
+		case "w":
+			o.Width, err = dimension(value)
+		case "h":
+			o.Height, err = dimension(value)
+		case "q":
+			o.Quality, err = strconv.Atoi(value)
+			if err == nil && (o.Quality < 1 || o.Quality > 100) {
+				err = fmt.Errorf("must be between 1 and 100")
+			}
+		case "fit":
+			if !fits[value] {
+				err = fmt.Errorf("must be fit, fill, exact or pad")
+			}
+			o.Fit = value
+		case "bg":
+			if _, perr := strconv.ParseUint(value, 16, 32); perr != nil || (len(value) != 3 && len(value) != 6 && len(value) != 8) {
+				err = fmt.Errorf("must be a hex color")
+			}
+			o.Background = strings.ToLower(value)
+		case "g":
+			if !gravities[value] {
+				err = fmt.Errorf("unknown gravity")
+			}
+			o.Gravity = value
+		default:
+			return o, fmt.Errorf("unknown option: %s", key)
+		}
+		if err != nil {
+			return o, fmt.Errorf("invalid %s: %s (%v)", key, value, err)
+		}
+	}
+
+	if o.Width == 0 && o.Height == 0 && o.Format == "" {
+		return o, fmt.Errorf("transformation must set a size or a format")
+	}
+	return o, nil
+}
+
+func dimension(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 || n > maxDimension {
+		return 0, fmt.Errorf("must be between 1 and %d", maxDimension)
+	}
+	return n, nil
+}
+
+// String returns the canonical form of the options, so equivalent
+// transformations share a cache entry regardless of option order.
+func (o Options) String() string {
+	var options []string
+	add := func(key, value string) {
+		options = append(options, key+"_"+value)
+	}
+
+	if o.Width > 0 {
+		add("w", strconv.Itoa(o.Width))
+	}
+	if o.Height > 0 {
+		add("h", strconv.Itoa(o.Height))
+	}
+	if o.Fit != "" {
+		add("fit", o.Fit)
+	}
+	if o.Gravity != "" {
+		add("g", o.Gravity)
+	}
+	if o.Background != "" {
+		add("bg", o.Background)
+	}
+	if o.Quality > 0 {
+		add("q", strconv.Itoa(o.Quality))
+	}
+
+	s := strings.Join(options, ",")
+	if o.Format != "" {
+		s += "." + o.Format
+	}
+	return s
+}
+
+// Step returns the processing step that applies the options: a resize when
+// a size is given, otherwise a format conversion.
+func (o Options) Step() models.PresetStep {
+	params := map[string]interface{}{}
+	if o.Quality > 0 {
+		params["quality"] = o.Quality
+	}
+
+	if o.Width == 0 && o.Height == 0 {
+		params["format"] = o.Format
+		return models.PresetStep{Operation: "convert", Parameters: params}
+	}
+
+	params["width"] = o.Width
+	params["height"] = o.Height
+	if o.Fit != "" {
+		params["mode"] = o.Fit
+	}
+	if o.Gravity != "" {
+		params["position"] = o.Gravity
+	}
+	if o.Background != "" {
+		params["background"] = "#" + o.Background
+	}
+	if o.Format != "" {
+		params["output_format"] = o.Format
+	}
+	return models.PresetStep{Operation: "resize", Parameters: params}
+}